@@ -0,0 +1,271 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kanisterio/kanister/pkg/apis/cr/v1alpha1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/tools/cache"
+	watchtools "k8s.io/client-go/tools/watch"
+)
+
+// outcomes of a backup deletion
+const (
+	deletionOutcomeComplete string = "complete"
+	deletionOutcomeFailed   string = "failed"
+	deletionOutcomeTimedOut string = "timed_out"
+	deletionOutcomeError    string = "error"
+)
+
+// errActionSetTimedOut is returned when an ActionSet does not finish within its timeout
+var errActionSetTimedOut = errors.New("timed out waiting for actionset")
+
+// reasons for which an ActionSet is skipped when retrieving backups
+const (
+	skipReasonMalformed       string = "malformed"
+	skipReasonMissingArtifact string = "missing-artifact"
+)
+
+// actionseterror is an error parsing an ActionSet, the reason is used as metric label
+type actionseterror struct {
+	name   string
+	reason string
+	err    error
+}
+
+func (actionsetErr *actionseterror) Error() string {
+	return fmt.Sprintf("actionset %v: %v: %v", actionsetErr.name, actionsetErr.reason, actionsetErr.err)
+}
+
+func (actionsetErr *actionseterror) Unwrap() error {
+	return actionsetErr.err
+}
+
+// the annotation on backup ActionSets with multiple backup actions which lists the indexes of the already pruned backup actions
+const prunedActionsAnnotation string = "taweret.io/pruned-actions"
+
+// parses every backup action of an ActionSet which belongs to the backup config into a backup. An error is returned if the ActionSet
+// cannot be parsed, or for every complete backup action without backup location artifact.
+func parseBackupActionSet(actionset unstructured.Unstructured, backupConfig backupconfig) ([]backup, []error) {
+	var typedActionSet v1alpha1.ActionSet
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(actionset.Object, &typedActionSet); err != nil {
+		return nil, []error{&actionseterror{name: actionset.GetName(), reason: skipReasonMalformed, err: err}}
+	}
+	if typedActionSet.Spec == nil || len(typedActionSet.Spec.Actions) == 0 {
+		return nil, []error{&actionseterror{name: actionset.GetName(), reason: skipReasonMalformed, err: errors.New("actionset has no actions")}}
+	}
+
+	var backups []backup
+	var actionsetErrors []error
+	backupActions := backupActionIndexes(&typedActionSet, backupConfig)
+	prunedActions := prunedActionIndexes(actionset.GetAnnotations())
+	held, holdUntil, err := parseHold(actionset.GetLabels(), actionset.GetAnnotations())
+	if err != nil {
+		log.Printf("%v: %v: %v\n", backupConfig.Name, actionset.GetName(), err)
+	}
+
+	for _, action := range backupActions {
+		actionSpec := typedActionSet.Spec.Actions[action]
+
+		// skip ahead if the backup action belongs to another backup config or has already been pruned
+		if actionSpec.Options[backupConfig.scheduleOptionKey()] != backupConfig.Name || prunedActions[action] {
+			continue
+		}
+
+		thisBackup := backup{
+			name:        typedActionSet.Name,
+			schedule:    actionSpec.Options[backupConfig.scheduleOptionKey()],
+			time:        typedActionSet.CreationTimestamp.Time.UTC(),
+			action:      action,
+			actionCount: len(backupActions),
+			held:        held,
+			holdUntil:   holdUntil,
+		}
+		if markedAt, ok := actionset.GetAnnotations()[thisBackup.markedForDeletionKey()]; ok {
+			// an invalid mark is replaced by the next evaluation
+			markedForDeletionAt, err := time.Parse(time.RFC3339, markedAt)
+			if err != nil {
+				log.Printf("%v: %v: invalid %v annotation %v: %v\n", backupConfig.Name, actionset.GetName(), thisBackup.markedForDeletionKey(), markedAt, err)
+			}
+			thisBackup.markedForDeletionAt = markedForDeletionAt
+		}
+
+		// an ActionSet without status has not been picked up by Kanister yet
+		if typedActionSet.Status == nil {
+			thisBackup.status = string(v1alpha1.StatePending)
+			backups = append(backups, thisBackup)
+			continue
+		}
+		thisBackup.status = string(typedActionSet.Status.State)
+
+		// the status of an action has the same index as its spec
+		if action < len(typedActionSet.Status.Actions) {
+			thisBackup.artifacts = typedActionSet.Status.Actions[action].Artifacts
+			thisBackup.backupLocation = thisBackup.artifacts[backupConfig.artifactName()].KeyValue[backupConfig.artifactKey()]
+		}
+
+		// a complete backup without backup location cannot be deleted
+		if thisBackup.status == string(v1alpha1.StateComplete) && thisBackup.backupLocation == "" {
+			actionsetErrors = append(actionsetErrors, &actionseterror{name: thisBackup.id(), reason: skipReasonMissingArtifact, err: fmt.Errorf("complete backup has no %v.%v artifact", backupConfig.artifactName(), backupConfig.artifactKey())})
+			continue
+		}
+
+		backups = append(backups, thisBackup)
+	}
+
+	return backups, actionsetErrors
+}
+
+// returns the indexes of the actions of an ActionSet which are backups created for Taweret, i.e. which have a schedule option
+func backupActionIndexes(actionset *v1alpha1.ActionSet, backupConfig backupconfig) []int {
+	var indexes []int
+	for i, actionSpec := range actionset.Spec.Actions {
+		if _, ok := actionSpec.Options[backupConfig.scheduleOptionKey()]; actionSpec.Name == backupConfig.backupActionName() && ok {
+			indexes = append(indexes, i)
+		}
+	}
+	return indexes
+}
+
+// returns the indexes of the already pruned backup actions listed in the annotations of an ActionSet
+func prunedActionIndexes(annotations map[string]string) map[int]bool {
+	pruned := map[int]bool{}
+	for _, index := range strings.Split(annotations[prunedActionsAnnotation], ",") {
+		if i, err := strconv.Atoi(strings.TrimSpace(index)); err == nil {
+			pruned[i] = true
+		}
+	}
+	return pruned
+}
+
+// marks the action of a deleted backup as pruned on its ActionSet, and deletes the ActionSet once all of its backup actions are pruned
+func pruneBackupAction(prunedBackup backup, dynamicClient dynamic.Interface, gvr schema.GroupVersionResource, backupConfig backupconfig) error {
+	actionsets := dynamicClient.Resource(gvr).Namespace(backupConfig.KanisterNamespace)
+
+	actionset, err := actionsets.Get(context.Background(), prunedBackup.name, v1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("error retrieving backup actionset: %w", err)
+	}
+	var typedActionSet v1alpha1.ActionSet
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(actionset.Object, &typedActionSet); err != nil {
+		return fmt.Errorf("error converting backup actionset: %w", err)
+	}
+
+	prunedActions := prunedActionIndexes(actionset.GetAnnotations())
+	prunedActions[prunedBackup.action] = true
+
+	var remainingActions []int
+	for _, action := range backupActionIndexes(&typedActionSet, backupConfig) {
+		if !prunedActions[action] {
+			remainingActions = append(remainingActions, action)
+		}
+	}
+
+	// delete backup actionset once none of its backup actions remain
+	if len(remainingActions) == 0 {
+		err = actionsets.Delete(context.Background(), prunedBackup.name, v1.DeleteOptions{})
+		if err != nil {
+			return fmt.Errorf("error deleting backup actionset: %w", err)
+		}
+		return nil
+	}
+
+	var prunedIndexes []int
+	for action := range prunedActions {
+		prunedIndexes = append(prunedIndexes, action)
+	}
+	sort.Ints(prunedIndexes)
+	var prunedList []string
+	for _, action := range prunedIndexes {
+		prunedList = append(prunedList, strconv.Itoa(action))
+	}
+
+	annotations := actionset.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[prunedActionsAnnotation] = strings.Join(prunedList, ",")
+	actionset.SetAnnotations(annotations)
+
+	log.Printf("%v: keeping backup actionset %v, backup actions %v remain\n", backupConfig.Name, prunedBackup.name, remainingActions)
+	_, err = actionsets.Update(context.Background(), actionset, v1.UpdateOptions{})
+	if err != nil {
+		return fmt.Errorf("error marking backup action as pruned: %w", err)
+	}
+	return nil
+}
+
+// identifies a backup by its ActionSet, and by its action if the ActionSet has multiple backup actions
+func (b backup) id() string {
+	if b.actionCount > 1 {
+		return fmt.Sprintf("%v-%v", b.name, b.action)
+	}
+	return b.name
+}
+
+// returns the state of an ActionSet and its error message, both are empty if the ActionSet has no status yet
+func actionSetState(actionset *unstructured.Unstructured) (string, string) {
+	state, _, _ := unstructured.NestedString(actionset.Object, "status", "state")
+	message, _, _ := unstructured.NestedString(actionset.Object, "status", "error", "message")
+	return state, message
+}
+
+// how long to wait for a deletion actionset of the backup config
+func (backupConfig backupconfig) deletionTimeout() time.Duration {
+	if timeout, err := time.ParseDuration(backupConfig.DeletionTimeout); err == nil && timeout > 0 {
+		return timeout
+	}
+	return *globalDeletionTimeout
+}
+
+// watches an ActionSet until it is complete or failed, returning its final state and error message. Returns errActionSetTimedOut with the
+// last observed state if the ActionSet does not finish within the timeout, e.g. because the Kanister controller does not pick it up.
+func waitForActionSet(dynamicClient dynamic.Interface, gvr schema.GroupVersionResource, namespace string, name string, timeout time.Duration) (string, string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	actionsets := dynamicClient.Resource(gvr).Namespace(namespace)
+	fieldSelector := fields.OneTermEqualSelector("metadata.name", name).String()
+	listWatch := &cache.ListWatch{
+		ListFunc: func(options v1.ListOptions) (runtime.Object, error) {
+			options.FieldSelector = fieldSelector
+			return actionsets.List(ctx, options)
+		},
+		WatchFunc: func(options v1.ListOptions) (watch.Interface, error) {
+			options.FieldSelector = fieldSelector
+			return actionsets.Watch(ctx, options)
+		},
+	}
+
+	var state, message string
+	_, err := watchtools.UntilWithSync(ctx, listWatch, &unstructured.Unstructured{}, nil, func(event watch.Event) (bool, error) {
+		actionset, ok := event.Object.(*unstructured.Unstructured)
+		if !ok || actionset.GetName() != name {
+			return false, nil
+		}
+		if event.Type == watch.Deleted {
+			return false, fmt.Errorf("actionset %v was deleted", name)
+		}
+		state, message = actionSetState(actionset)
+		log.Printf("%v: %v\n", name, valueOrDefault(state, "waiting for kanister"))
+		return state == string(v1alpha1.StateComplete) || state == string(v1alpha1.StateFailed), nil
+	})
+	if errors.Is(err, wait.ErrWaitTimeout) || ctx.Err() != nil {
+		return state, message, errActionSetTimedOut
+	}
+	return state, message, err
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	fake "k8s.io/client-go/dynamic/fake"
+)
+
+func TestParseBackupActionSet(t *testing.T) {
+	var backupConfig backupconfig
+	backupConfig.Name = "daily"
+
+	withoutStatus := newUnstructuredBackup("backup-new", "kanister", "2022-01-01T02:03:04Z", "backup", "daily", "", "")
+	delete(withoutStatus.Object, "status")
+
+	withoutActions := newUnstructuredBackup("backup-no-actions", "kanister", "2022-01-01T02:03:04Z", "backup", "daily", "complete", "")
+	withoutActions.Object["spec"] = map[string]interface{}{"actions": []interface{}{}}
+
+	withoutArtifacts := newUnstructuredBackup("backup-no-artifacts", "kanister", "2022-01-01T02:03:04Z", "backup", "daily", "complete", "")
+	withoutArtifacts.Object["status"].(map[string]interface{})["actions"] = []interface{}{}
+
+	tests := []struct {
+		name       string
+		actionset  *unstructured.Unstructured
+		isBackup   bool
+		status     string
+		skipReason string
+	}{
+		{name: "complete backup", actionset: newUnstructuredBackup("backup-foo", "kanister", "2022-01-01T02:03:04Z", "backup", "daily", "complete", "pg_backups/backup.sql.gz"), isBackup: true, status: "complete"},
+		{name: "other schedule", actionset: newUnstructuredBackup("backup-bar", "kanister", "2022-01-01T02:03:04Z", "backup", "weekly", "complete", "pg_backups/backup.sql.gz")},
+		{name: "not a backup", actionset: newUnstructuredBackup("restore-foo", "kanister", "2022-01-01T02:03:04Z", "restore", "daily", "complete", "pg_backups/backup.sql.gz")},
+		{name: "no status yet", actionset: withoutStatus, isBackup: true, status: "pending"},
+		{name: "no actions", actionset: withoutActions, skipReason: skipReasonMalformed},
+		{name: "complete without artifacts", actionset: withoutArtifacts, skipReason: skipReasonMissingArtifact},
+	}
+
+	for _, test := range tests {
+		backups, actionsetErrors := parseBackupActionSet(*test.actionset, backupConfig)
+		if test.skipReason != "" {
+			var actionsetErr *actionseterror
+			if len(actionsetErrors) != 1 || !errors.As(actionsetErrors[0], &actionsetErr) || actionsetErr.reason != test.skipReason {
+				t.Fatalf("%v: expected skip reason %v, got %v", test.name, test.skipReason, actionsetErrors)
+			}
+			continue
+		}
+		if len(actionsetErrors) > 0 {
+			t.Fatalf("%v: unexpected errors: %v", test.name, actionsetErrors)
+		}
+		if (len(backups) == 1) != test.isBackup || (test.isBackup && backups[0].status != test.status) {
+			t.Fatalf("%v: expected backup %v with status %v, got %+v", test.name, test.isBackup, test.status, backups)
+		}
+	}
+}
+
+func TestMultipleBackupActions(t *testing.T) {
+	var backupConfig backupconfig
+	backupConfig.Name = "daily"
+	backupConfig.KanisterNamespace = "kanister"
+
+	// an ActionSet backing up two StatefulSets, and a restore action in between
+	actionset := newUnstructuredBackup("backup-multi", "kanister", "2022-01-01T02:03:04Z", "backup", "daily", "complete", "pg_backups/first/backup.sql.gz")
+	spec := actionset.Object["spec"].(map[string]interface{})
+	spec["actions"] = append(spec["actions"].([]interface{}),
+		map[string]interface{}{"name": "restore"},
+		map[string]interface{}{"name": "backup", "options": map[string]interface{}{"backup-schedule": "daily"}},
+	)
+	status := actionset.Object["status"].(map[string]interface{})
+	status["actions"] = append(status["actions"].([]interface{}),
+		map[string]interface{}{},
+		map[string]interface{}{"artifacts": map[string]interface{}{"cloudObject": map[string]interface{}{"keyValue": map[string]interface{}{"backupLocation": "pg_backups/second/backup.sql.gz"}}}},
+	)
+
+	backups, actionsetErrors := parseBackupActionSet(*actionset, backupConfig)
+	if len(actionsetErrors) > 0 {
+		t.Fatal(actionsetErrors)
+	}
+	if len(backups) != 2 || backups[0].action != 0 || backups[1].action != 2 || backups[1].backupLocation != "pg_backups/second/backup.sql.gz" {
+		t.Fatalf("Expected two backups with their own artifacts, got %+v", backups)
+	}
+	if backups[0].id() != "backup-multi-0" || backups[1].id() != "backup-multi-2" {
+		t.Fatalf("Unexpected backup ids: %v, %v", backups[0].id(), backups[1].id())
+	}
+
+	gvr := schema.GroupVersionResource{Group: "cr.kanister.io", Version: "v1alpha1", Resource: "actionsets"}
+	client := fake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{gvr: "ActionSetsList"},
+		actionset,
+	)
+
+	// pruning the first backup action keeps the ActionSet and marks the action as pruned
+	if err := pruneBackupAction(backups[0], client, gvr, backupConfig); err != nil {
+		t.Fatal(err)
+	}
+	updatedActionSet, err := client.Resource(gvr).Namespace("kanister").Get(context.Background(), "backup-multi", v1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updatedActionSet.GetAnnotations()[prunedActionsAnnotation] != "0" {
+		t.Fatalf("Expected the first action to be marked as pruned, got %v", updatedActionSet.GetAnnotations())
+	}
+	backups, _ = parseBackupActionSet(*updatedActionSet, backupConfig)
+	if len(backups) != 1 || backups[0].action != 2 {
+		t.Fatalf("Expected only the second backup action to remain, got %+v", backups)
+	}
+
+	// pruning the last backup action deletes the ActionSet
+	if err := pruneBackupAction(backups[0], client, gvr, backupConfig); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Resource(gvr).Namespace("kanister").Get(context.Background(), "backup-multi", v1.GetOptions{}); err == nil {
+		t.Fatal("Expected the ActionSet to be deleted once all backup actions are pruned")
+	}
+}
+
+func TestWaitForActionSet(t *testing.T) {
+	gvr := schema.GroupVersionResource{Group: "cr.kanister.io", Version: "v1alpha1", Resource: "actionsets"}
+	newDeletionActionSet := func(name string, state string) *unstructured.Unstructured {
+		actionset := &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "cr.kanister.io/v1alpha1",
+			"kind":       "ActionSet",
+			"metadata":   map[string]interface{}{"namespace": "kanister", "name": name},
+		}}
+		if state != "" {
+			actionset.Object["status"] = map[string]interface{}{"state": state}
+		}
+		return actionset
+	}
+
+	client := fake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{gvr: "ActionSetsList"},
+		newDeletionActionSet("delete-complete", "complete"),
+		newDeletionActionSet("delete-stuck", ""),
+		newDeletionActionSet("delete-running", "running"),
+	)
+
+	state, _, err := waitForActionSet(client, gvr, "kanister", "delete-complete", time.Second)
+	if err != nil || state != "complete" {
+		t.Fatalf("Expected a complete actionset, got %v, %v", state, err)
+	}
+
+	// an actionset which Kanister never picks up times out
+	_, _, err = waitForActionSet(client, gvr, "kanister", "delete-stuck", 200*time.Millisecond)
+	if !errors.Is(err, errActionSetTimedOut) {
+		t.Fatalf("Expected the actionset to time out, got %v", err)
+	}
+
+	// a running actionset is watched until it fails
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		failed := newDeletionActionSet("delete-running", "failed")
+		failed.Object["status"].(map[string]interface{})["error"] = map[string]interface{}{"message": "no such key"}
+		client.Resource(gvr).Namespace("kanister").Update(context.Background(), failed, v1.UpdateOptions{})
+	}()
+	state, message, err := waitForActionSet(client, gvr, "kanister", "delete-running", 5*time.Second)
+	if err != nil || state != "failed" || message != "no such key" {
+		t.Fatalf("Expected a failed actionset, got %v, %v, %v", state, message, err)
+	}
+}
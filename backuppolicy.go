@@ -0,0 +1,168 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	taweretv1alpha1 "github.com/swissdatasciencecenter/taweret/pkg/apis/taweret/v1alpha1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+)
+
+// queries Kubernetes for BackupPolicies and returns them as backup configs. BackupPolicies which cannot be read are returned as errors.
+func getBackupPolicies(dynamicClient dynamic.Interface) ([]backupconfig, []error) {
+	var backupConfigs []backupconfig
+	var configErrors []error
+
+	var policies []unstructured.Unstructured
+	for _, namespace := range watchedConfigNamespaces() {
+		policyList, err := dynamicClient.Resource(taweretv1alpha1.BackupPolicyResource).Namespace(namespace).List(context.Background(), v1.ListOptions{})
+		if errors.IsNotFound(err) {
+			log.Printf("BackupPolicy CRD is not installed, skipping BackupPolicies\n")
+			return nil, nil
+		}
+		if err != nil {
+			configErrors = append(configErrors, &configerror{source: "backuppolicies", err: fmt.Errorf("error getting BackupPolicies: %w", err)})
+			continue
+		}
+		policies = append(policies, policyList.Items...)
+	}
+
+	for i := range policies {
+		backupConfig, err := backupConfigFromUnstructuredPolicy(&policies[i])
+		if err != nil {
+			configErrors = append(configErrors, err)
+			continue
+		}
+		backupConfigs = append(backupConfigs, backupConfig)
+
+		log.Printf("backup policy:\n name: %v\n kanister namespace: %v\n blueprint name: %v\n profile name: %v\n retention:\n backups: %v\n years: %v months: %v days: %v hours %v minutes: %v", backupConfig.Name, backupConfig.KanisterNamespace, backupConfig.BlueprintName, backupConfig.ProfileName, backupConfig.Retention.Backups, backupConfig.Retention.Years, backupConfig.Retention.Months, backupConfig.Retention.Days, backupConfig.Retention.Hours, backupConfig.Retention.Minutes)
+	}
+	return backupConfigs, configErrors
+}
+
+// converts an unstructured BackupPolicy to a backup config
+func backupConfigFromUnstructuredPolicy(item *unstructured.Unstructured) (backupconfig, error) {
+	var policy taweretv1alpha1.BackupPolicy
+	err := runtime.DefaultUnstructuredConverter.FromUnstructured(item.Object, &policy)
+	if err != nil {
+		return backupconfig{}, &configerror{source: "backuppolicy/" + item.GetNamespace() + "/" + item.GetName(), err: fmt.Errorf("error converting BackupPolicy: %w", err), object: item}
+	}
+	backupConfig := backupConfigFromPolicy(&policy)
+	backupConfig.source = "backuppolicy/" + item.GetNamespace() + "/" + item.GetName()
+	backupConfig.object = item
+	return backupConfig, nil
+}
+
+// converts a BackupPolicy to a backup config, the name of the policy is the backup schedule it applies to
+func backupConfigFromPolicy(policy *taweretv1alpha1.BackupPolicy) backupconfig {
+	var backupConfig backupconfig
+	backupConfig.Name = policy.Name
+	backupConfig.KanisterNamespace = policy.Spec.KanisterNamespace
+	backupConfig.BlueprintName = policy.Spec.BlueprintName
+	backupConfig.ProfileName = policy.Spec.ProfileName
+	backupConfig.BackupActionName = policy.Spec.BackupActionName
+	backupConfig.DeleteActionName = policy.Spec.DeleteActionName
+	backupConfig.ScheduleOptionKey = policy.Spec.ScheduleOptionKey
+	backupConfig.ArtifactName = policy.Spec.ArtifactName
+	backupConfig.ArtifactKey = policy.Spec.ArtifactKey
+	backupConfig.ArtifactMapping = policy.Spec.ArtifactMapping
+	backupConfig.Retention.Backups = StringInt(policy.Spec.Retention.Backups)
+	backupConfig.Retention.Minutes = StringInt(policy.Spec.Retention.Minutes)
+	backupConfig.Retention.Hours = StringInt(policy.Spec.Retention.Hours)
+	backupConfig.Retention.Days = StringInt(policy.Spec.Retention.Days)
+	backupConfig.Retention.Months = StringInt(policy.Spec.Retention.Months)
+	backupConfig.Retention.Years = StringInt(policy.Spec.Retention.Years)
+	backupConfig.Retention.KeepHourly = StringInt(policy.Spec.Retention.KeepHourly)
+	backupConfig.Retention.KeepDaily = StringInt(policy.Spec.Retention.KeepDaily)
+	backupConfig.Retention.KeepWeekly = StringInt(policy.Spec.Retention.KeepWeekly)
+	backupConfig.Retention.KeepMonthly = StringInt(policy.Spec.Retention.KeepMonthly)
+	backupConfig.Retention.KeepYearly = StringInt(policy.Spec.Retention.KeepYearly)
+	backupConfig.Retention.AllowZeroBackups = policy.Spec.Retention.AllowZeroBackups
+	if policy.Spec.Retention.KeepFailed != nil {
+		keepFailed := StringInt(*policy.Spec.Retention.KeepFailed)
+		backupConfig.Retention.KeepFailed = &keepFailed
+	}
+	if policy.Spec.Retention.KeepSkipped != nil {
+		keepSkipped := StringInt(*policy.Spec.Retention.KeepSkipped)
+		backupConfig.Retention.KeepSkipped = &keepSkipped
+	}
+	backupConfig.MinKeep = StringInt(policy.Spec.MinKeep)
+	if policy.Spec.NewestBackupMaxAge != nil {
+		backupConfig.NewestBackupMaxAge = policy.Spec.NewestBackupMaxAge.Duration.String()
+	}
+	backupConfig.DryRun = policy.Spec.DryRun
+	if policy.Spec.DeletionTimeout != nil {
+		backupConfig.DeletionTimeout = policy.Spec.DeletionTimeout.Duration.String()
+	}
+	if policy.Spec.DeletionGracePeriod != nil {
+		backupConfig.DeletionGracePeriod = policy.Spec.DeletionGracePeriod.Duration.String()
+	}
+	if policy.Spec.DeletionMaxRetries != nil {
+		maxRetries := StringInt(*policy.Spec.DeletionMaxRetries)
+		backupConfig.DeletionMaxRetries = &maxRetries
+	}
+	if policy.Spec.DeletionRetryBackoff != nil {
+		backupConfig.DeletionRetryBackoff = policy.Spec.DeletionRetryBackoff.Duration.String()
+	}
+	if policy.Spec.StalePendingAfter != nil {
+		backupConfig.StalePendingAfter = policy.Spec.StalePendingAfter.Duration.String()
+	}
+	if expected := policy.Spec.ExpectedBackups; expected != nil {
+		if expected.Interval != nil {
+			backupConfig.ExpectedBackups.Interval = expected.Interval.Duration.String()
+		}
+		backupConfig.ExpectedBackups.Schedule = expected.Schedule
+		if expected.Tolerance != nil {
+			backupConfig.ExpectedBackups.Tolerance = expected.Tolerance.Duration.String()
+		}
+	}
+	backupConfig.EvaluationSchedule = policy.Spec.EvaluationSchedule
+	backupConfig.Timezone = policy.Spec.Timezone
+	backupConfig.policy = policy
+	return backupConfig
+}
+
+// records the result of an evaluation in the status subresource of the BackupPolicy a backup config was read from
+func updatePolicyStatus(dynamicClient dynamic.Interface, backupConfig backupconfig, result evaluationresult, evaluationErr error) {
+	if backupConfig.policy == nil {
+		return
+	}
+
+	now := v1.NewTime(time.Now())
+	status := taweretv1alpha1.BackupPolicyStatus{
+		ObservedGeneration: backupConfig.policy.Generation,
+		LastEvaluationTime: &now,
+		RetainedBackups:    int32(result.retained),
+		DeletedBackups:     int32(result.deleted),
+	}
+
+	statusPatch, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&status)
+	if err != nil {
+		log.Printf("%v: error converting BackupPolicy status: %v\n", backupConfig.Name, err)
+		return
+	}
+	// an explicit null removes the error of a previous evaluation
+	statusPatch["error"] = nil
+	if evaluationErr != nil {
+		statusPatch["error"] = evaluationErr.Error()
+	}
+
+	patch, err := json.Marshal(map[string]interface{}{"status": statusPatch})
+	if err != nil {
+		log.Printf("%v: error marshalling BackupPolicy status: %v\n", backupConfig.Name, err)
+		return
+	}
+
+	_, err = dynamicClient.Resource(taweretv1alpha1.BackupPolicyResource).Namespace(backupConfig.policy.Namespace).Patch(context.Background(), backupConfig.policy.Name, types.MergePatchType, patch, v1.PatchOptions{}, "status")
+	if err != nil {
+		log.Printf("%v: error updating BackupPolicy status: %v\n", backupConfig.Name, err)
+	}
+}
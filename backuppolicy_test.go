@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	taweretv1alpha1 "github.com/swissdatasciencecenter/taweret/pkg/apis/taweret/v1alpha1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	fake "k8s.io/client-go/dynamic/fake"
+)
+
+func TestBackupPolicies(t *testing.T) {
+	policy := &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": "taweret.io/v1alpha1",
+			"kind":       "BackupPolicy",
+			"metadata": map[string]interface{}{
+				"namespace":  configNamespace,
+				"name":       "daily-postgres",
+				"generation": int64(2),
+			},
+			"spec": map[string]interface{}{
+				"kanisterNamespace": "kanister",
+				"blueprintName":     "postgres-bp",
+				"profileName":       "default-profile",
+				"retention": map[string]interface{}{
+					"backups":   int64(7),
+					"days":      int64(7),
+					"keepDaily": int64(3),
+				},
+			},
+			"status": map[string]interface{}{
+				"error": "a previous error",
+			},
+		},
+	}
+
+	client := fake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{
+			taweretv1alpha1.BackupPolicyResource: "BackupPolicyList",
+		},
+		policy,
+	)
+
+	backupConfigs, configErrors := getBackupPolicies(client)
+	if len(configErrors) > 0 {
+		t.Fatal(configErrors)
+	}
+	if len(backupConfigs) != 1 {
+		t.Fatalf("Expected one backup config, got %v", len(backupConfigs))
+	}
+	backupConfig := backupConfigs[0]
+	if backupConfig.Name != "daily-postgres" || backupConfig.BlueprintName != "postgres-bp" || backupConfig.Retention.Backups != 7 || backupConfig.Retention.Days != 7 || backupConfig.Retention.KeepDaily != 3 {
+		t.Fatalf("Unexpected backup config: %+v", backupConfig)
+	}
+
+	updatePolicyStatus(client, backupConfig, evaluationresult{retained: 5, deleted: 2}, nil)
+
+	updatedPolicy, err := client.Resource(taweretv1alpha1.BackupPolicyResource).Namespace(configNamespace).Get(context.Background(), "daily-postgres", v1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var typedPolicy taweretv1alpha1.BackupPolicy
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(updatedPolicy.Object, &typedPolicy); err != nil {
+		t.Fatal(err)
+	}
+	status := typedPolicy.Status
+	if status.RetainedBackups != 5 || status.DeletedBackups != 2 || status.ObservedGeneration != 2 || status.LastEvaluationTime == nil || status.Error != "" {
+		t.Fatalf("Unexpected BackupPolicy status: %+v", status)
+	}
+}
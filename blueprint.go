@@ -0,0 +1,67 @@
+package main
+
+import (
+	"github.com/kanisterio/kanister/pkg/apis/cr/v1alpha1"
+)
+
+// the action, option and artifact names of the stock postgres blueprint, used when a backup config does not set them
+const (
+	defaultBackupActionName  string = "backup"
+	defaultDeleteActionName  string = "delete"
+	defaultScheduleOptionKey string = "backup-schedule"
+	defaultArtifactName      string = "cloudObject"
+	defaultArtifactKey       string = "backupLocation"
+)
+
+// the name of the blueprint action which creates backups
+func (backupConfig backupconfig) backupActionName() string {
+	return valueOrDefault(backupConfig.BackupActionName, defaultBackupActionName)
+}
+
+// the name of the blueprint action which deletes backups
+func (backupConfig backupconfig) deleteActionName() string {
+	return valueOrDefault(backupConfig.DeleteActionName, defaultDeleteActionName)
+}
+
+// the key of the ActionSet option which holds the backup schedule
+func (backupConfig backupconfig) scheduleOptionKey() string {
+	return valueOrDefault(backupConfig.ScheduleOptionKey, defaultScheduleOptionKey)
+}
+
+// the name of the backup artifact which holds the backup location
+func (backupConfig backupconfig) artifactName() string {
+	return valueOrDefault(backupConfig.ArtifactName, defaultArtifactName)
+}
+
+// the key of the backup artifact which holds the backup location
+func (backupConfig backupconfig) artifactKey() string {
+	return valueOrDefault(backupConfig.ArtifactKey, defaultArtifactKey)
+}
+
+// returns every artifact of a backup as input artifacts of its deletion actionset, renamed according to the artifact mapping of the backup config
+func deletionArtifacts(unusedBackup backup, backupConfig backupconfig) map[string]v1alpha1.Artifact {
+	artifacts := map[string]v1alpha1.Artifact{}
+	for name, artifact := range unusedBackup.artifacts {
+		if mappedName, ok := backupConfig.ArtifactMapping[name]; ok {
+			name = mappedName
+		}
+		artifacts[name] = artifact
+	}
+
+	// backups without recorded artifacts still pass their backup location
+	if len(artifacts) == 0 && unusedBackup.backupLocation != "" {
+		artifacts[backupConfig.artifactName()] = v1alpha1.Artifact{
+			KeyValue: map[string]string{
+				backupConfig.artifactKey(): unusedBackup.backupLocation,
+			},
+		}
+	}
+	return artifacts
+}
+
+func valueOrDefault(value string, defaultValue string) string {
+	if value == "" {
+		return defaultValue
+	}
+	return value
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/kanisterio/kanister/pkg/apis/cr/v1alpha1"
+)
+
+func TestCustomBlueprintNames(t *testing.T) {
+	var backupConfig backupconfig
+	backupConfig.Name = "nightly"
+	backupConfig.BackupActionName = "dump"
+	backupConfig.DeleteActionName = "deleteDump"
+	backupConfig.ScheduleOptionKey = "schedule"
+	backupConfig.ArtifactName = "mysqlCloudDump"
+	backupConfig.ArtifactKey = "s3path"
+	backupConfig.ArtifactMapping = map[string]string{"mysqlCloudDump": "mysqlBackup"}
+
+	actionset := newUnstructuredBackup("dump-foo", "kanister", "2022-01-01T02:03:04Z", "dump", "", "complete", "")
+	actionset.Object["spec"].(map[string]interface{})["actions"].([]interface{})[0].(map[string]interface{})["options"] = map[string]interface{}{"schedule": "nightly"}
+	actionset.Object["status"].(map[string]interface{})["actions"].([]interface{})[0].(map[string]interface{})["artifacts"] = map[string]interface{}{
+		"mysqlCloudDump": map[string]interface{}{"keyValue": map[string]interface{}{"s3path": "mysql/dump.sql.gz", "size": "42"}},
+		"logs":           map[string]interface{}{"keyValue": map[string]interface{}{"path": "mysql/dump.log"}},
+	}
+
+	backups, actionsetErrors := parseBackupActionSet(*actionset, backupConfig)
+	if len(actionsetErrors) > 0 {
+		t.Fatal(actionsetErrors)
+	}
+	if len(backups) != 1 || backups[0].backupLocation != "mysql/dump.sql.gz" {
+		t.Fatalf("Expected one backup with the s3path of the mysqlCloudDump artifact, got %+v", backups)
+	}
+
+	artifacts := deletionArtifacts(backups[0], backupConfig)
+	expected := map[string]v1alpha1.Artifact{
+		"mysqlBackup": {KeyValue: map[string]string{"s3path": "mysql/dump.sql.gz", "size": "42"}},
+		"logs":        {KeyValue: map[string]string{"path": "mysql/dump.log"}},
+	}
+	if len(artifacts) != len(expected) {
+		t.Fatalf("Expected every artifact to be passed to the deletion actionset, got %v", artifacts)
+	}
+	for name, artifact := range expected {
+		for key, value := range artifact.KeyValue {
+			if artifacts[name].KeyValue[key] != value {
+				t.Fatalf("Expected artifact %v to have %v=%v, got %v", name, key, value, artifacts)
+			}
+		}
+	}
+
+	// the stock postgres blueprint names are used by default
+	if (backupconfig{}).backupActionName() != "backup" || (backupconfig{}).artifactKey() != "backupLocation" {
+		t.Fatal("Expected the names of the stock postgres blueprint by default")
+	}
+}
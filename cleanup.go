@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+)
+
+// how long a backup ActionSet can be pending before it is reported as stale, zero if stale ActionSets are not reported
+func (backupConfig backupconfig) stalePendingAfter() time.Duration {
+	if after, err := time.ParseDuration(backupConfig.StalePendingAfter); err == nil && after > 0 {
+		return after
+	}
+	return *globalStalePendingAfter
+}
+
+// backupcleanup lists the backup ActionSets which did not produce a complete backup and are cleaned up or reported
+type backupcleanup struct {
+	// failed and skipped backups beyond the retention of the backup config, oldest first
+	backups []backup
+	// backups which have been pending for longer than the stale pending threshold
+	stalePending []backup
+}
+
+// applies the retention of failed and skipped backups, keeping the newest keepFailed and keepSkipped backups. Without keepFailed or
+// keepSkipped, the backups of that state are kept. Held backups are always kept.
+func planCleanup(backups []backup, backupConfig backupconfig, now time.Time) backupcleanup {
+	var result backupcleanup
+	var failedBackups, skippedBackups []backup
+	stalePendingAfter := backupConfig.stalePendingAfter()
+
+	for _, aBackup := range backups {
+		if aBackup.isHeld(now) {
+			continue
+		}
+		switch aBackup.status {
+		case "failed", "attemptfailed":
+			failedBackups = append(failedBackups, aBackup)
+		case "skipped":
+			skippedBackups = append(skippedBackups, aBackup)
+		case "pending":
+			if stalePendingAfter > 0 && now.Sub(aBackup.time) > stalePendingAfter {
+				result.stalePending = append(result.stalePending, aBackup)
+			}
+		}
+	}
+
+	result.backups = append(excessBackups(failedBackups, backupConfig.Retention.KeepFailed), excessBackups(skippedBackups, backupConfig.Retention.KeepSkipped)...)
+	return result
+}
+
+// returns the oldest backups beyond the newest keep backups, none if keep is not set
+func excessBackups(backups []backup, keep *StringInt) []backup {
+	if keep == nil || len(backups) <= int(*keep) {
+		return nil
+	}
+	sorted := make([]backup, len(backups))
+	copy(sorted, backups)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].time.Before(sorted[j].time) })
+	return sorted[:len(sorted)-int(*keep)]
+}
+
+// whether a backup recorded any artifacts, e.g. a partial upload of a failed backup which has to be deleted by the blueprint
+func (b backup) hasArtifacts() bool {
+	for _, artifact := range b.artifacts {
+		if artifact.KopiaSnapshot != "" {
+			return true
+		}
+		for _, value := range artifact.KeyValue {
+			if value != "" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// cleans up failed and skipped backups. Backups with artifacts are deleted with the delete action of the blueprint, so that their
+// partial data is not orphaned, the other backups are pruned directly. Returns the number of cleaned up backups.
+func cleanupBackups(cleanup backupcleanup, dynamicClient dynamic.Interface, gvr schema.GroupVersionResource, taweretMetrics taweretmetrics, backupConfig backupconfig, now time.Time) (int, error) {
+	cleaned := 0
+	for _, aBackup := range cleanup.backups {
+		if aBackup.hasArtifacts() {
+			if retryAt := aBackup.deletion.retryAt(backupConfig); now.Before(retryAt) {
+				log.Printf("%v: deletion of %v backup %v failed, retrying after %v\n", backupConfig.Name, aBackup.status, aBackup.id(), retryAt.UTC())
+				continue
+			}
+			log.Printf("%v: cleaning up %v backup %v with its artifacts, backup time: %v\n", backupConfig.Name, aBackup.status, aBackup.id(), aBackup.time.UTC())
+			outcome, err := deleteBackup(aBackup, dynamicClient, gvr, backupConfig)
+			taweretMetrics.recordDeletion(outcome, backupConfig)
+			if err != nil {
+				return cleaned, fmt.Errorf("error deleting %v backup %v: %w", aBackup.status, aBackup.id(), err)
+			}
+			if outcome != deletionOutcomeComplete {
+				continue
+			}
+		} else {
+			log.Printf("%v: cleaning up %v backup %v, backup time: %v\n", backupConfig.Name, aBackup.status, aBackup.id(), aBackup.time.UTC())
+			if err := pruneBackupAction(aBackup, dynamicClient, gvr, backupConfig); err != nil {
+				return cleaned, fmt.Errorf("error pruning %v backup %v: %w", aBackup.status, aBackup.id(), err)
+			}
+		}
+		taweretMetrics.cleanedActionSets.WithLabelValues(backupConfig.Name, aBackup.status).Inc()
+		cleaned++
+	}
+	return cleaned, nil
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"k8s.io/apimachinery/pkg/api/errors"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	fake "k8s.io/client-go/dynamic/fake"
+)
+
+func TestPlanCleanup(t *testing.T) {
+	now := time.Now()
+	backups := []backup{
+		{name: "backup-failed-1", status: "failed", time: now.Add(-3 * time.Hour)},
+		{name: "backup-failed-2", status: "attemptfailed", time: now.Add(-2 * time.Hour)},
+		{name: "backup-failed-3", status: "failed", time: now.Add(-1 * time.Hour)},
+		{name: "backup-failed-held", status: "failed", time: now.Add(-4 * time.Hour), held: true},
+		{name: "backup-skipped", status: "skipped", time: now.Add(-4 * time.Hour)},
+		{name: "backup-pending", status: "pending", time: now.Add(-1 * time.Hour)},
+		{name: "backup-stuck", status: "pending", time: now.Add(-7 * time.Hour)},
+		{name: "backup-complete", status: "complete", time: now.Add(-5 * time.Hour)},
+	}
+
+	var backupConfig backupconfig
+	backupConfig.Name = "daily"
+	backupConfig.StalePendingAfter = "6h"
+	keepFailed := StringInt(1)
+	backupConfig.Retention.KeepFailed = &keepFailed
+
+	cleanup := planCleanup(backups, backupConfig, now)
+	if len(cleanup.backups) != 2 || cleanup.backups[0].name != "backup-failed-1" || cleanup.backups[1].name != "backup-failed-2" {
+		t.Fatalf("Expected the two oldest failed backups to be cleaned up, got %+v", cleanup.backups)
+	}
+	if len(cleanup.stalePending) != 1 || cleanup.stalePending[0].name != "backup-stuck" {
+		t.Fatalf("Expected the backup pending for 7h to be stale, got %+v", cleanup.stalePending)
+	}
+
+	// skipped backups are only cleaned up once keepSkipped is set
+	keepSkipped := StringInt(0)
+	backupConfig.Retention.KeepSkipped = &keepSkipped
+	cleanup = planCleanup(backups, backupConfig, now)
+	if len(cleanup.backups) != 3 || cleanup.backups[2].name != "backup-skipped" {
+		t.Fatalf("Expected the skipped backup to be cleaned up with keepSkipped: 0, got %+v", cleanup.backups)
+	}
+}
+
+func TestCleanupBackups(t *testing.T) {
+	gvr := schema.GroupVersionResource{Group: "cr.kanister.io", Version: "v1alpha1", Resource: "actionsets"}
+	var backupConfig backupconfig
+	backupConfig.Name = "daily"
+	backupConfig.KanisterNamespace = "kanister"
+	backupConfig.DeletionTimeout = "5s"
+	keepFailed := StringInt(0)
+	backupConfig.Retention.KeepFailed = &keepFailed
+
+	created := time.Now().Add(-1 * time.Hour).UTC().Format(time.RFC3339)
+	client := fake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{gvr: "ActionSetsList"},
+		newUnstructuredBackup("backup-empty", "kanister", created, "backup", "daily", "failed", ""),
+		newUnstructuredBackup("backup-partial", "kanister", created, "backup", "daily", "failed", "pg_backups/partial/backup.sql.gz"),
+	)
+	actionsets := client.Resource(gvr).Namespace("kanister")
+
+	// kanister completes the deletion of the partial backup
+	go func() {
+		for {
+			deletion, err := actionsets.Get(context.Background(), "delete-backup-partial", v1.GetOptions{})
+			if err == nil {
+				unstructured.SetNestedField(deletion.Object, "complete", "status", "state")
+				actionsets.Update(context.Background(), deletion, v1.UpdateOptions{})
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}()
+
+	backups, _, err := getBackups(client, gvr, backupConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	taweretMetrics := newTaweretMetrics()
+	cleaned, err := cleanupBackups(planCleanup(backups, backupConfig, time.Now()), client, gvr, taweretMetrics, backupConfig, time.Now())
+	if err != nil || cleaned != 2 {
+		t.Fatalf("Expected both failed backups to be cleaned up, got %v, %v", cleaned, err)
+	}
+
+	for _, name := range []string{"backup-empty", "backup-partial"} {
+		if _, err := actionsets.Get(context.Background(), name, v1.GetOptions{}); !errors.IsNotFound(err) {
+			t.Fatalf("Expected %v to be deleted, got %v", name, err)
+		}
+	}
+	if _, err := actionsets.Get(context.Background(), "delete-backup-empty", v1.GetOptions{}); !errors.IsNotFound(err) {
+		t.Fatalf("Expected the failed backup without artifacts to be pruned without deletion actionset, got %v", err)
+	}
+	if deletions := testutil.ToFloat64(taweretMetrics.deletions.WithLabelValues("daily", deletionOutcomeComplete)); deletions != 1 {
+		t.Fatalf("Expected the partial backup to be deleted by the blueprint, got %v deletions", deletions)
+	}
+	if cleanedUp := testutil.ToFloat64(taweretMetrics.cleanedActionSets.WithLabelValues("daily", "failed")); cleanedUp != 2 {
+		t.Fatalf("Expected two cleaned up failed actionsets, got %v", cleanedUp)
+	}
+}
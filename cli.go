@@ -0,0 +1,331 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+)
+
+const commandUsage string = `usage: taweret [flags] [command] [command flags]
+
+Without a command, Taweret schedules backup evaluations and serves metrics.
+
+commands:
+  list     list the backups of every backup config
+  plan     show which backups would be deleted and why
+  prune    evaluate a backup config now, deleting its expired and excess backups
+  status   show the amount of backups per state of every backup config
+  unmark   restore backup ActionSets marked for deletion and hold them for one grace period, e.g. unmark --config NAME ACTIONSET...
+
+command flags:
+  --config NAME   only use the backup config NAME, required for prune and unmark
+  --output FORMAT table or json, table by default
+  --verbose       log the evaluation steps to stderr
+`
+
+// commandoutput is the output of a command, printed as a table or as JSON
+type commandoutput struct {
+	headers []string
+	rows    [][]string
+	json    interface{}
+}
+
+type backupJSON struct {
+	Config         string    `json:"config"`
+	ActionSet      string    `json:"actionSet"`
+	Action         int       `json:"action"`
+	Created        time.Time `json:"created"`
+	Status         string    `json:"status"`
+	Retention      string    `json:"retention"`
+	BackupLocation string    `json:"backupLocation"`
+}
+
+type planJSON struct {
+	Config    string                `json:"config"`
+	Deletions []plannedDeletionJSON `json:"deletions"`
+}
+
+type pruneJSON struct {
+	Config   string `json:"config"`
+	DryRun   bool   `json:"dryRun"`
+	Retained int    `json:"retained"`
+	Deleted  int    `json:"deleted"`
+}
+
+type statusJSON struct {
+	Config   string `json:"config"`
+	Complete int    `json:"complete"`
+	Expired  int    `json:"expired"`
+	Pending  int    `json:"pending"`
+	Running  int    `json:"running"`
+	Failed   int    `json:"failed"`
+	Skipped  int    `json:"skipped"`
+	Deleting int    `json:"deleting"`
+	Held     int    `json:"held"`
+
+	DeletionFailed int `json:"deletionFailed"`
+}
+
+type unmarkJSON struct {
+	Config    string `json:"config"`
+	ActionSet string `json:"actionSet"`
+	Restored  bool   `json:"restored"`
+	// whether the backup is held, and until when, empty if it is held indefinitely
+	Held      bool   `json:"held"`
+	HeldUntil string `json:"heldUntil,omitempty"`
+}
+
+// runs a command line subcommand on the backup configs and writes its output to out
+func runCommand(args []string, out io.Writer, dynamicClient dynamic.Interface, gvr schema.GroupVersionResource, clientSet kubernetes.Interface, taweretMetrics taweretmetrics) error {
+	flags := flag.NewFlagSet(args[0], flag.ContinueOnError)
+	configName := flags.String("config", "", "only use this backup config")
+	outputFormat := flags.String("output", "table", "the output format, table or json")
+	verbose := flags.Bool("verbose", false, "log the evaluation steps to stderr")
+	flags.Usage = func() { fmt.Fprint(flags.Output(), commandUsage) }
+	if err := flags.Parse(args[1:]); err != nil {
+		return err
+	}
+	if *outputFormat != "table" && *outputFormat != "json" {
+		return fmt.Errorf("unknown output format %v", *outputFormat)
+	}
+
+	// the evaluation steps are only logged on request, so that they do not clutter the output
+	if !*verbose {
+		log.SetOutput(io.Discard)
+		defer log.SetOutput(os.Stderr)
+	}
+
+	backupConfigs, configErrors := loadBackupConfigs(dynamicClient, gvr, clientSet)
+	for _, err := range configErrors {
+		fmt.Fprintf(os.Stderr, "warning: skipping backup config: %v\n", err)
+	}
+	if *configName != "" {
+		backupConfigs = filterBackupConfigs(backupConfigs, *configName)
+		if len(backupConfigs) == 0 {
+			return fmt.Errorf("backup config %v not found", *configName)
+		}
+	}
+
+	var output commandoutput
+	var err error
+	switch args[0] {
+	case "list":
+		output, err = listCommand(backupConfigs, dynamicClient, gvr)
+	case "plan":
+		output, err = planCommand(backupConfigs, dynamicClient, gvr)
+	case "prune":
+		if *configName == "" {
+			return errors.New("prune requires --config NAME")
+		}
+		output, err = pruneCommand(backupConfigs, dynamicClient, gvr, taweretMetrics)
+	case "status":
+		output, err = statusCommand(backupConfigs, dynamicClient, gvr)
+	case "unmark":
+		if *configName == "" || flags.NArg() == 0 {
+			return errors.New("unmark requires --config NAME and at least one ACTIONSET")
+		}
+		output, err = unmarkCommand(backupConfigs[0], flags.Args(), dynamicClient, gvr)
+	default:
+		fmt.Fprint(os.Stderr, commandUsage)
+		return fmt.Errorf("unknown command %v", args[0])
+	}
+	if err != nil {
+		return err
+	}
+
+	if *outputFormat == "json" {
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(output.json)
+	}
+	return printTable(out, output)
+}
+
+// lists the backups of every backup config, with whether they are retained or expired
+func listCommand(backupConfigs []backupconfig, dynamicClient dynamic.Interface, gvr schema.GroupVersionResource) (commandoutput, error) {
+	output := commandoutput{headers: []string{"CONFIG", "ACTIONSET", "ACTION", "CREATED", "STATUS", "RETENTION"}}
+	backupList := []backupJSON{}
+
+	for _, backupConfig := range backupConfigs {
+		backups, _, err := getBackups(dynamicClient, gvr, backupConfig)
+		if err != nil {
+			return output, fmt.Errorf("%v: %w", backupConfig.Name, err)
+		}
+		categorisedBackups, expiredBackups, _ := categoriseBackups(backups, backupConfig)
+
+		retention := map[string]string{}
+		for _, aBackup := range categorisedBackups {
+			retention[aBackup.id()] = "retained"
+		}
+		for _, aBackup := range expiredBackups {
+			retention[aBackup.id()] = "expired"
+		}
+		now := time.Now()
+		for _, aBackup := range backups {
+			if aBackup.status == "complete" && aBackup.isHeld(now) {
+				retention[aBackup.id()] = "held"
+			}
+		}
+
+		for _, aBackup := range sortBackups(backups, backupConfig) {
+			backupList = append(backupList, backupJSON{
+				Config:         backupConfig.Name,
+				ActionSet:      aBackup.name,
+				Action:         aBackup.action,
+				Created:        aBackup.time,
+				Status:         aBackup.status,
+				Retention:      valueOrDefault(retention[aBackup.id()], "-"),
+				BackupLocation: aBackup.backupLocation,
+			})
+		}
+	}
+
+	for _, aBackup := range backupList {
+		output.rows = append(output.rows, []string{aBackup.Config, aBackup.ActionSet, strconv.Itoa(aBackup.Action), aBackup.Created.Format(time.RFC3339), aBackup.Status, aBackup.Retention})
+	}
+	output.json = backupList
+	return output, nil
+}
+
+// shows which backups of every backup config would be deleted and why, without deleting them
+func planCommand(backupConfigs []backupconfig, dynamicClient dynamic.Interface, gvr schema.GroupVersionResource) (commandoutput, error) {
+	output := commandoutput{headers: []string{"CONFIG", "ACTIONSET", "ACTION", "CREATED", "REASON"}}
+	plans := []planJSON{}
+
+	for _, backupConfig := range backupConfigs {
+		backups, _, err := getBackups(dynamicClient, gvr, backupConfig)
+		if err != nil {
+			return output, fmt.Errorf("%v: %w", backupConfig.Name, err)
+		}
+		categorisedBackups, expiredBackups, _ := categoriseBackups(backups, backupConfig)
+
+		configPlan := planJSON{Config: backupConfig.Name, Deletions: []plannedDeletionJSON{}}
+		plan, _ := planDeletions(categorisedBackups, expiredBackups, backupConfig)
+		for _, deletion := range plan {
+			configPlan.Deletions = append(configPlan.Deletions, plannedDeletionJSON{
+				ActionSet:  deletion.backup.name,
+				Action:     deletion.backup.action,
+				BackupTime: deletion.backup.time,
+				Reason:     deletion.reason,
+			})
+			output.rows = append(output.rows, []string{backupConfig.Name, deletion.backup.name, strconv.Itoa(deletion.backup.action), deletion.backup.time.Format(time.RFC3339), deletion.reason})
+		}
+		plans = append(plans, configPlan)
+	}
+
+	output.json = plans
+	return output, nil
+}
+
+// evaluates a backup config now, deleting its expired and excess backups unless dry run mode is enabled
+func pruneCommand(backupConfigs []backupconfig, dynamicClient dynamic.Interface, gvr schema.GroupVersionResource, taweretMetrics taweretmetrics) (commandoutput, error) {
+	output := commandoutput{headers: []string{"CONFIG", "DRY RUN", "RETAINED", "DELETED"}}
+	results := []pruneJSON{}
+
+	for _, backupConfig := range backupConfigs {
+		result, err := evaluateBackups(dynamicClient, gvr, taweretMetrics, backupConfig)
+		updatePolicyStatus(dynamicClient, backupConfig, result, err)
+		if err != nil {
+			return output, fmt.Errorf("%v: %w", backupConfig.Name, err)
+		}
+		results = append(results, pruneJSON{Config: backupConfig.Name, DryRun: backupConfig.DryRun, Retained: result.retained, Deleted: result.deleted})
+		output.rows = append(output.rows, []string{backupConfig.Name, strconv.FormatBool(backupConfig.DryRun), strconv.Itoa(result.retained), strconv.Itoa(result.deleted)})
+	}
+
+	output.json = results
+	return output, nil
+}
+
+// shows the amount of backups per state of every backup config
+func statusCommand(backupConfigs []backupconfig, dynamicClient dynamic.Interface, gvr schema.GroupVersionResource) (commandoutput, error) {
+	output := commandoutput{headers: []string{"CONFIG", "COMPLETE", "EXPIRED", "PENDING", "RUNNING", "FAILED", "SKIPPED", "DELETING", "HELD", "DELETION FAILED"}}
+	statuses := []statusJSON{}
+
+	for _, backupConfig := range backupConfigs {
+		backups, _, err := getBackups(dynamicClient, gvr, backupConfig)
+		if err != nil {
+			return output, fmt.Errorf("%v: %w", backupConfig.Name, err)
+		}
+		categorisedBackups, expiredBackups, backupCounts := categoriseBackups(backups, backupConfig)
+
+		status := statusJSON{
+			Config:   backupConfig.Name,
+			Complete: len(categorisedBackups),
+			Expired:  len(expiredBackups),
+			Pending:  backupCounts.pending,
+			Running:  backupCounts.running,
+			Failed:   backupCounts.failed,
+			Skipped:  backupCounts.skipped,
+			Deleting: backupCounts.deleting,
+			Held:     backupCounts.held,
+
+			DeletionFailed: backupCounts.deletionFailed,
+		}
+		statuses = append(statuses, status)
+		output.rows = append(output.rows, []string{status.Config, strconv.Itoa(status.Complete), strconv.Itoa(status.Expired), strconv.Itoa(status.Pending), strconv.Itoa(status.Running), strconv.Itoa(status.Failed), strconv.Itoa(status.Skipped), strconv.Itoa(status.Deleting), strconv.Itoa(status.Held), strconv.Itoa(status.DeletionFailed)})
+	}
+
+	output.json = statuses
+	return output, nil
+}
+
+// restores backup ActionSets marked for deletion, holding them for one grace period so that they are not marked again right away
+func unmarkCommand(backupConfig backupconfig, actionSets []string, dynamicClient dynamic.Interface, gvr schema.GroupVersionResource) (commandoutput, error) {
+	output := commandoutput{headers: []string{"CONFIG", "ACTIONSET", "RESTORED", "HELD UNTIL"}}
+	results := []unmarkJSON{}
+	now := time.Now()
+
+	for _, actionSet := range actionSets {
+		held, holdUntil, err := restoreBackup(dynamicClient, gvr, backupConfig, actionSet, now)
+		if err != nil {
+			return output, fmt.Errorf("%v: %w", backupConfig.Name, err)
+		}
+		result := unmarkJSON{Config: backupConfig.Name, ActionSet: actionSet, Restored: true, Held: held}
+		heldUntil := "-"
+		switch {
+		case held && holdUntil.IsZero():
+			heldUntil = "indefinitely"
+		case held:
+			result.HeldUntil = holdUntil.UTC().Format(time.RFC3339)
+			heldUntil = result.HeldUntil
+		}
+		results = append(results, result)
+		output.rows = append(output.rows, []string{backupConfig.Name, actionSet, "true", heldUntil})
+	}
+
+	output.json = results
+	return output, nil
+}
+
+// returns the backup configs with the given name
+func filterBackupConfigs(backupConfigs []backupconfig, name string) []backupconfig {
+	var filtered []backupconfig
+	for _, backupConfig := range backupConfigs {
+		if backupConfig.Name == name {
+			filtered = append(filtered, backupConfig)
+		}
+	}
+	return filtered
+}
+
+// prints the output of a command as an aligned table
+func printTable(out io.Writer, output commandoutput) error {
+	writer := tabwriter.NewWriter(out, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(writer, strings.Join(output.headers, "\t"))
+	for _, row := range output.rows {
+		fmt.Fprintln(writer, strings.Join(row, "\t"))
+	}
+	return writer.Flush()
+}
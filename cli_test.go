@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	taweretv1alpha1 "github.com/swissdatasciencecenter/taweret/pkg/apis/taweret/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	fake "k8s.io/client-go/dynamic/fake"
+	kubernetesfake "k8s.io/client-go/kubernetes/fake"
+)
+
+func TestCommands(t *testing.T) {
+	gvr := schema.GroupVersionResource{Group: "cr.kanister.io", Version: "v1alpha1", Resource: "actionsets"}
+	now := time.Now().UTC()
+	dynamicClient := fake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{
+			gvr:                                  "ActionSetsList",
+			taweretv1alpha1.BackupPolicyResource: "BackupPolicyList",
+		},
+		newUnstructuredBackup("backup-new", "kanister", now.Add(-1*time.Hour).Format(time.RFC3339), "backup", "daily", "complete", "pg_backups/new/backup.sql.gz"),
+		newUnstructuredBackup("backup-old", "kanister", now.AddDate(0, 0, -10).Format(time.RFC3339), "backup", "daily", "complete", "pg_backups/old/backup.sql.gz"),
+		newUnstructuredBackup("backup-failed", "kanister", now.Add(-2*time.Hour).Format(time.RFC3339), "backup", "daily", "failed", ""),
+	)
+	clientSet := kubernetesfake.NewSimpleClientset(&corev1.ConfigMap{
+		ObjectMeta: v1.ObjectMeta{Name: "taweret-backupconfig-daily", Namespace: configNamespace},
+		Data:       map[string]string{"backup-config.yaml": "name: daily\nkanisterNamespace: kanister\nblueprintName: postgres-bp\nprofileName: default-profile\nretention:\n  backups: 7\n  days: 7\n"},
+	})
+
+	var out bytes.Buffer
+	if err := runCommand([]string{"list", "--output", "json"}, &out, dynamicClient, gvr, clientSet, taweretmetrics{}); err != nil {
+		t.Fatal(err)
+	}
+	var backupList []backupJSON
+	if err := json.Unmarshal(out.Bytes(), &backupList); err != nil {
+		t.Fatal(err)
+	}
+	if len(backupList) != 3 || backupList[0].ActionSet != "backup-old" || backupList[0].Retention != "expired" || backupList[2].Retention != "retained" {
+		t.Fatalf("Unexpected backup list: %+v", backupList)
+	}
+
+	out.Reset()
+	if err := runCommand([]string{"plan", "--config", "daily"}, &out, dynamicClient, gvr, clientSet, taweretmetrics{}); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 || !strings.HasPrefix(lines[0], "CONFIG") || !strings.Contains(lines[1], "backup-old") || !strings.Contains(lines[1], deletionReasonAge) {
+		t.Fatalf("Unexpected plan output:\n%v", out.String())
+	}
+
+	out.Reset()
+	if err := runCommand([]string{"status", "-output", "json"}, &out, dynamicClient, gvr, clientSet, taweretmetrics{}); err != nil {
+		t.Fatal(err)
+	}
+	var statuses []statusJSON
+	if err := json.Unmarshal(out.Bytes(), &statuses); err != nil {
+		t.Fatal(err)
+	}
+	if len(statuses) != 1 || statuses[0].Complete != 1 || statuses[0].Expired != 1 || statuses[0].Failed != 1 {
+		t.Fatalf("Unexpected status: %+v", statuses)
+	}
+
+	if err := runCommand([]string{"prune"}, &out, dynamicClient, gvr, clientSet, taweretmetrics{}); err == nil {
+		t.Fatal("Expected prune without --config to fail")
+	}
+	if err := runCommand([]string{"list", "--config", "weekly"}, &out, dynamicClient, gvr, clientSet, taweretmetrics{}); err == nil {
+		t.Fatal("Expected an unknown backup config to fail")
+	}
+}
+
+func TestUnmarkCommand(t *testing.T) {
+	gvr := schema.GroupVersionResource{Group: "cr.kanister.io", Version: "v1alpha1", Resource: "actionsets"}
+	now := time.Now().UTC()
+	marked := newUnstructuredBackup("backup-old", "kanister", now.AddDate(0, 0, -10).Format(time.RFC3339), "backup", "daily", "complete", "pg_backups/old/backup.sql.gz")
+	marked.SetAnnotations(map[string]string{markedForDeletionAnnotation: now.Format(time.RFC3339)})
+	dynamicClient := fake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{
+			gvr:                                  "ActionSetsList",
+			taweretv1alpha1.BackupPolicyResource: "BackupPolicyList",
+		},
+		marked,
+	)
+	clientSet := kubernetesfake.NewSimpleClientset(&corev1.ConfigMap{
+		ObjectMeta: v1.ObjectMeta{Name: "taweret-backupconfig-daily", Namespace: configNamespace},
+		Data:       map[string]string{"backup-config.yaml": "name: daily\nkanisterNamespace: kanister\nblueprintName: postgres-bp\nprofileName: default-profile\ndeletionGracePeriod: 72h\nretention:\n  backups: 7\n  days: 7\n"},
+	})
+
+	var out bytes.Buffer
+	if err := runCommand([]string{"unmark", "--config", "daily"}, &out, dynamicClient, gvr, clientSet, taweretmetrics{}); err == nil {
+		t.Fatal("Expected unmark without an ActionSet to fail")
+	}
+	if err := runCommand([]string{"unmark", "--config", "daily", "backup-old"}, &out, dynamicClient, gvr, clientSet, taweretmetrics{}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "backup-old") {
+		t.Fatalf("Unexpected unmark output:\n%v", out.String())
+	}
+
+	out.Reset()
+	if err := runCommand([]string{"list", "--output", "json"}, &out, dynamicClient, gvr, clientSet, taweretmetrics{}); err != nil {
+		t.Fatal(err)
+	}
+	var backupList []backupJSON
+	if err := json.Unmarshal(out.Bytes(), &backupList); err != nil {
+		t.Fatal(err)
+	}
+	if len(backupList) != 1 || backupList[0].Retention != "held" {
+		t.Fatalf("Expected the unmarked backup to be held, got %+v", backupList)
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"sync"
+)
+
+// configLocks prevents overlapping evaluations of the same backup config, e.g. when an evaluation takes longer than the evaluation schedule
+var configLocks = &configlocks{locks: map[string]*sync.Mutex{}}
+
+// deletionSlots limits the number of deletion actionsets running at the same time across all backup configs
+var deletionSlots = &slots{size: maxInflightDeletions}
+
+// evaluationSlots limits the number of backup configs evaluated at the same time
+var evaluationSlots = &slots{size: concurrency}
+
+type configlocks struct {
+	mutex sync.Mutex
+	locks map[string]*sync.Mutex
+}
+
+// slots is a semaphore whose size is read from a flag on first use
+type slots struct {
+	size  *int
+	once  sync.Once
+	slots chan struct{}
+}
+
+// locks the backup config with the given name, returning false if it is already locked. The returned function unlocks the config.
+func (configLocks *configlocks) tryLock(name string) (func(), bool) {
+	configLocks.mutex.Lock()
+	lock, ok := configLocks.locks[name]
+	if !ok {
+		lock = &sync.Mutex{}
+		configLocks.locks[name] = lock
+	}
+	configLocks.mutex.Unlock()
+
+	if !lock.TryLock() {
+		return nil, false
+	}
+	return lock.Unlock, true
+}
+
+// blocks until a slot is free and takes it. The returned function frees the slot.
+func (slots *slots) acquire() func() {
+	slots.once.Do(func() {
+		slots.slots = make(chan struct{}, maxInt(*slots.size, 1))
+	})
+	slots.slots <- struct{}{}
+	return func() { <-slots.slots }
+}
+
+func maxInt(a int, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestConfigLocks(t *testing.T) {
+	locks := &configlocks{locks: map[string]*sync.Mutex{}}
+
+	unlock, ok := locks.tryLock("daily")
+	if !ok {
+		t.Fatal("Expected to lock an unlocked config")
+	}
+	if _, ok := locks.tryLock("daily"); ok {
+		t.Fatal("Expected a running evaluation of the same config to keep it locked")
+	}
+	unlockWeekly, ok := locks.tryLock("weekly")
+	if !ok {
+		t.Fatal("Expected other configs to be evaluated in parallel")
+	}
+	unlockWeekly()
+
+	unlock()
+	if _, ok := locks.tryLock("daily"); !ok {
+		t.Fatal("Expected the config to be unlocked after its evaluation")
+	}
+}
+
+func TestSlots(t *testing.T) {
+	size := 1
+	slots := &slots{size: &size}
+
+	release := slots.acquire()
+	acquired := make(chan struct{})
+	go func() {
+		slots.acquire()()
+		close(acquired)
+	}()
+
+	select {
+	case <-acquired:
+		t.Fatal("Expected the second deletion to wait for a free slot")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	release()
+	select {
+	case <-acquired:
+	case <-time.After(time.Second):
+		t.Fatal("Expected the second deletion to start once the slot is free")
+	}
+}
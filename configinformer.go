@@ -0,0 +1,243 @@
+package main
+
+import (
+	"context"
+	goerrors "errors"
+	"log"
+	"reflect"
+	"sort"
+	"sync"
+
+	taweretv1alpha1 "github.com/swissdatasciencecenter/taweret/pkg/apis/taweret/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+)
+
+// configset is the in-memory set of backup configs, keyed by the object they are defined in, e.g. configmap/<namespace>/<name>.
+// Only valid configs with a unique name are active.
+type configset struct {
+	mutex      sync.Mutex
+	candidates map[string]backupconfig
+	active     []backupconfig
+	// the validation error of every rejected source, so that errors are only reported once
+	rejected   map[string]string
+	generation int64
+	// called with the active backup configs whenever a backup config is added, changed or removed. onChange is called without holding
+	// mutex, notifyMutex orders the calls and notified is the generation of the last call.
+	onChange       func([]backupconfig)
+	notifyMutex    sync.Mutex
+	notified       int64
+	taweretMetrics taweretmetrics
+}
+
+func newConfigSet(taweretMetrics taweretmetrics, onChange func([]backupconfig)) *configset {
+	return &configset{candidates: map[string]backupconfig{}, rejected: map[string]string{}, onChange: onChange, taweretMetrics: taweretMetrics}
+}
+
+// watches ConfigMaps and BackupPolicies in the watched namespaces and keeps the config set up to date, blocking until the initial configs are loaded
+func watchBackupConfigs(ctx context.Context, dynamicClient dynamic.Interface, clientSet kubernetes.Interface, configSet *configset) {
+	namespaces := watchedConfigNamespaces()
+	var configInformers []cache.SharedIndexInformer
+	for _, namespace := range namespaces {
+		configMapInformer := informers.NewSharedInformerFactoryWithOptions(clientSet, 0, informers.WithNamespace(namespace), informers.WithTweakListOptions(func(options *v1.ListOptions) {
+			options.LabelSelector = *configSelector
+		})).Core().V1().ConfigMaps().Informer()
+		configMapInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+			AddFunc:    func(obj interface{}) { configSet.setConfigMap(obj) },
+			UpdateFunc: func(_, obj interface{}) { configSet.setConfigMap(obj) },
+			DeleteFunc: func(obj interface{}) { configSet.deleteSource(obj, "configmap/") },
+		})
+		configInformers = append(configInformers, configMapInformer)
+	}
+
+	// BackupPolicies are only watched if the CRD is installed
+	_, err := dynamicClient.Resource(taweretv1alpha1.BackupPolicyResource).Namespace(namespaces[0]).List(ctx, v1.ListOptions{Limit: 1})
+	if errors.IsNotFound(err) {
+		log.Printf("BackupPolicy CRD is not installed, skipping BackupPolicies\n")
+	} else {
+		for _, namespace := range namespaces {
+			policyInformer := dynamicinformer.NewFilteredDynamicSharedInformerFactory(dynamicClient, 0, namespace, nil).ForResource(taweretv1alpha1.BackupPolicyResource).Informer()
+			policyInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+				AddFunc:    func(obj interface{}) { configSet.setPolicy(obj) },
+				UpdateFunc: func(_, obj interface{}) { configSet.setPolicy(obj) },
+				DeleteFunc: func(obj interface{}) { configSet.deleteSource(obj, "backuppolicy/") },
+			})
+			configInformers = append(configInformers, policyInformer)
+		}
+	}
+
+	var hasSynced []cache.InformerSynced
+	for _, informer := range configInformers {
+		go informer.Run(ctx.Done())
+		hasSynced = append(hasSynced, informer.HasSynced)
+	}
+	if !cache.WaitForCacheSync(ctx.Done(), hasSynced...) {
+		log.Printf("error waiting for the backup config informers to sync\n")
+	}
+}
+
+func (configSet *configset) setConfigMap(obj interface{}) {
+	configmap, ok := obj.(*corev1.ConfigMap)
+	if !ok {
+		return
+	}
+	source := "configmap/" + configmap.Namespace + "/" + configmap.Name
+	backupConfig, ok, err := backupConfigFromConfigMap(configmap)
+	configSet.set(source, backupConfig, ok, err)
+}
+
+func (configSet *configset) setPolicy(obj interface{}) {
+	item, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return
+	}
+	backupConfig, err := backupConfigFromUnstructuredPolicy(item)
+	configSet.set("backuppolicy/"+item.GetNamespace()+"/"+item.GetName(), backupConfig, err == nil, err)
+}
+
+// removes the backup config of a deleted object, the object may be a tombstone if the deletion was missed
+func (configSet *configset) deleteSource(obj interface{}, prefix string) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	object, ok := obj.(v1.Object)
+	if !ok {
+		return
+	}
+	configSet.set(prefix+object.GetNamespace()+"/"+object.GetName(), backupconfig{}, false, nil)
+}
+
+// stores the backup config of a source, a config which cannot be read or an object without a config removes the config of the source
+func (configSet *configset) set(source string, backupConfig backupconfig, ok bool, err error) {
+	if configSet.store(source, backupConfig, ok, err) {
+		configSet.notify()
+	}
+}
+
+// stores the backup config of a source and activates the configs, returns whether the candidates changed
+func (configSet *configset) store(source string, backupConfig backupconfig, ok bool, err error) bool {
+	configSet.mutex.Lock()
+	defer configSet.mutex.Unlock()
+
+	if err != nil {
+		log.Printf("skipping backup config: %v\n", err)
+		configSet.taweretMetrics.recordConfigError(err)
+	}
+
+	previous, existed := configSet.candidates[source]
+	if ok {
+		backupConfig = withGlobalDryRun(backupConfig)
+		configSet.candidates[source] = backupConfig
+		if existed && sameBackupConfig(previous, backupConfig) {
+			// e.g. a status update of a BackupPolicy or a resync
+			return false
+		}
+		log.Printf("%v: backup config from %v loaded\n", backupConfig.Name, source)
+	} else {
+		if !existed {
+			return false
+		}
+		delete(configSet.candidates, source)
+		log.Printf("%v: backup config from %v removed\n", previous.Name, source)
+	}
+	configSet.activate()
+	return true
+}
+
+// calls onChange with the latest active backup configs unless they have already been passed, so that the informers are not blocked by
+// onChange and concurrent changes are passed in order
+func (configSet *configset) notify() {
+	configSet.notifyMutex.Lock()
+	defer configSet.notifyMutex.Unlock()
+
+	configSet.mutex.Lock()
+	backupConfigs, generation := configSet.active, configSet.generation
+	configSet.mutex.Unlock()
+
+	if generation == configSet.notified || configSet.onChange == nil {
+		return
+	}
+	configSet.notified = generation
+	configSet.onChange(backupConfigs)
+}
+
+// validates the candidates and activates the valid configs, e.g. a duplicate becomes active once the config which used its name is removed
+func (configSet *configset) activate() {
+	var candidates []backupconfig
+	for _, backupConfig := range configSet.candidates {
+		candidates = append(candidates, backupConfig)
+	}
+	backupConfigs, configErrors := validateBackupConfigs(candidates)
+	sort.Slice(backupConfigs, func(i, j int) bool { return backupConfigs[i].Name < backupConfigs[j].Name })
+
+	rejected := map[string]string{}
+	for _, err := range configErrors {
+		var configErr *configerror
+		if !goerrors.As(err, &configErr) {
+			continue
+		}
+		rejected[configErr.source] = configErr.err.Error()
+		if configSet.rejected[configErr.source] != rejected[configErr.source] {
+			log.Printf("skipping backup config: %v\n", err)
+			configSet.taweretMetrics.recordConfigError(err)
+		}
+	}
+	configSet.rejected = rejected
+
+	if sameBackupConfigs(configSet.active, backupConfigs) {
+		return
+	}
+	// remove the series of a backup config which is no longer active, e.g. after it was deleted, renamed or became invalid. The series
+	// written by an evaluation still running are removed once its job has stopped.
+	for _, previous := range configSet.active {
+		if !containsBackupConfig(backupConfigs, previous.Name) {
+			configSet.taweretMetrics.deleteConfigSeries(previous.Name)
+		}
+	}
+	configSet.active = backupConfigs
+
+	configSet.generation++
+	configSet.taweretMetrics.activeConfigs.Set(float64(len(backupConfigs)))
+	configSet.taweretMetrics.configGeneration.Set(float64(configSet.generation))
+}
+
+// compares two backup configs, BackupPolicies are compared by generation so that status updates are not changes
+func sameBackupConfig(a backupconfig, b backupconfig) bool {
+	if (a.policy == nil) != (b.policy == nil) {
+		return false
+	}
+	if a.policy != nil && a.policy.Generation != b.policy.Generation {
+		return false
+	}
+	a.policy, b.policy = nil, nil
+	a.object, b.object = nil, nil
+	return reflect.DeepEqual(a, b)
+}
+
+func sameBackupConfigs(a []backupconfig, b []backupconfig) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !sameBackupConfig(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func containsBackupConfig(backupConfigs []backupconfig, name string) bool {
+	for _, backupConfig := range backupConfigs {
+		if backupConfig.Name == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	taweretv1alpha1 "github.com/swissdatasciencecenter/taweret/pkg/apis/taweret/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	fake "k8s.io/client-go/dynamic/fake"
+	kubernetesfake "k8s.io/client-go/kubernetes/fake"
+)
+
+func TestWatchBackupConfigs(t *testing.T) {
+	clientSet := kubernetesfake.NewSimpleClientset(
+		&corev1.ConfigMap{
+			ObjectMeta: v1.ObjectMeta{Name: "taweret-backupconfig-daily", Namespace: configNamespace},
+			Data:       map[string]string{"backup-config.yaml": "name: daily\nkanisterNamespace: kanister\nblueprintName: postgres-bp\nprofileName: default-profile\nretention:\n  backups: 7\n"},
+		},
+		&corev1.ConfigMap{
+			ObjectMeta: v1.ObjectMeta{Name: "unrelated", Namespace: configNamespace},
+			Data:       map[string]string{"foo": "bar"},
+		},
+	)
+	dynamicClient := fake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{
+			taweretv1alpha1.BackupPolicyResource: "BackupPolicyList",
+		},
+		&unstructured.Unstructured{
+			Object: map[string]interface{}{
+				"apiVersion": "taweret.io/v1alpha1",
+				"kind":       "BackupPolicy",
+				"metadata": map[string]interface{}{
+					"namespace": configNamespace,
+					"name":      "weekly",
+				},
+				"spec": map[string]interface{}{
+					"kanisterNamespace": "kanister",
+					"blueprintName":     "postgres-bp",
+					"profileName":       "default-profile",
+					"retention": map[string]interface{}{
+						"backups": int64(3),
+					},
+				},
+			},
+		},
+	)
+
+	var mutex sync.Mutex
+	var active []backupconfig
+	taweretMetrics := newTaweretMetrics()
+	configSet := newConfigSet(taweretMetrics, func(backupConfigs []backupconfig) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		active = backupConfigs
+	})
+	activeNames := func() []string {
+		mutex.Lock()
+		defer mutex.Unlock()
+		var names []string
+		for _, backupConfig := range active {
+			names = append(names, backupConfig.Name)
+		}
+		return names
+	}
+	waitFor := func(description string, condition func() bool) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for !condition() {
+			if time.Now().After(deadline) {
+				t.Fatalf("Timed out waiting for %v, active configs: %v", description, activeNames())
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	watchBackupConfigs(ctx, dynamicClient, clientSet, configSet)
+
+	waitFor("the ConfigMap and BackupPolicy configs", func() bool {
+		names := activeNames()
+		return len(names) == 2 && names[0] == "daily" && names[1] == "weekly"
+	})
+	if testutil.ToFloat64(taweretMetrics.activeConfigs) != 2 {
+		t.Fatalf("Expected 2 active configs, got %v", testutil.ToFloat64(taweretMetrics.activeConfigs))
+	}
+	generation := testutil.ToFloat64(taweretMetrics.configGeneration)
+
+	// an update takes effect immediately
+	_, err := clientSet.CoreV1().ConfigMaps(configNamespace).Update(ctx, &corev1.ConfigMap{
+		ObjectMeta: v1.ObjectMeta{Name: "taweret-backupconfig-daily", Namespace: configNamespace},
+		Data:       map[string]string{"backup-config.yaml": "name: daily\nkanisterNamespace: kanister\nblueprintName: postgres-bp\nprofileName: default-profile\nretention:\n  backups: 3\n"},
+	}, v1.UpdateOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitFor("the updated config", func() bool {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return len(active) == 2 && active[0].Retention.Backups == 3
+	})
+	if testutil.ToFloat64(taweretMetrics.configGeneration) <= generation {
+		t.Fatal("Expected the config generation to increase")
+	}
+
+	// the series of a removed config are deleted
+	taweretMetrics.backupCount.WithLabelValues("daily", "completed").Set(3)
+	taweretMetrics.backupCount.WithLabelValues("weekly", "completed").Set(1)
+	err = clientSet.CoreV1().ConfigMaps(configNamespace).Delete(ctx, "taweret-backupconfig-daily", v1.DeleteOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitFor("the removed config", func() bool {
+		names := activeNames()
+		return len(names) == 1 && names[0] == "weekly"
+	})
+	if count := testutil.CollectAndCount(taweretMetrics.backupCount); count != 1 {
+		t.Fatalf("Expected only the series of the weekly config, got %v series", count)
+	}
+}
+
+func TestSameBackupConfig(t *testing.T) {
+	a := backupconfig{Name: "daily", policy: &taweretv1alpha1.BackupPolicy{ObjectMeta: v1.ObjectMeta{Generation: 1}}}
+	b := backupconfig{Name: "daily", policy: &taweretv1alpha1.BackupPolicy{ObjectMeta: v1.ObjectMeta{Generation: 1}, Status: taweretv1alpha1.BackupPolicyStatus{RetainedBackups: 3}}}
+	if !sameBackupConfig(a, b) {
+		t.Fatal("Expected a status update not to change the backup config")
+	}
+	b.policy.Generation = 2
+	if sameBackupConfig(a, b) {
+		t.Fatal("Expected a new generation to change the backup config")
+	}
+}
+
+func TestConfigSetNotifiesWithoutLock(t *testing.T) {
+	release := make(chan struct{})
+	notified := make(chan []backupconfig, 2)
+	configSet := newConfigSet(newTaweretMetrics(), func(backupConfigs []backupconfig) {
+		notified <- backupConfigs
+		<-release
+	})
+
+	go configSet.set("configmap/kanister/daily", newValidBackupConfig("daily", "configmap/kanister/daily"), true, nil)
+	select {
+	case backupConfigs := <-notified:
+		if len(backupConfigs) != 1 {
+			t.Fatalf("Expected the daily config, got %+v", backupConfigs)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the config set to notify the change")
+	}
+
+	// a blocking onChange does not block storing other configs
+	stored := make(chan struct{})
+	go func() {
+		configSet.store("configmap/kanister/weekly", newValidBackupConfig("weekly", "configmap/kanister/weekly"), true, nil)
+		close(stored)
+	}()
+	select {
+	case <-stored:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the config to be stored while onChange is running")
+	}
+
+	// the latest configs are passed once onChange returns
+	go configSet.notify()
+	close(release)
+	select {
+	case backupConfigs := <-notified:
+		if len(backupConfigs) != 2 {
+			t.Fatalf("Expected the daily and weekly configs, got %+v", backupConfigs)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the latest configs to be notified")
+	}
+}
@@ -0,0 +1,337 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/kanisterio/kanister/pkg/apis/cr/v1alpha1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/dynamic"
+)
+
+// deletion ActionSets are labelled with the backup they delete, so that they can be found again after a restart
+const (
+	// the backup id, or a hash of it if it is not a valid label value. The annotation with the same key holds the backup id.
+	deletesKey = "taweret.io/deletes"
+	// the backup config whose backup is deleted, hashed like the backup id. The annotation with the same key holds the name.
+	backupConfigNameKey = "taweret.io/backup-config-name"
+	// the attempt to delete the backup, starting at 0, increased whenever a deletion failed
+	deletionGenerationAnnotation = "taweret.io/deletion-generation"
+	// set when Taweret gave up waiting for the deletion, which is then retried like a failed deletion
+	deletionTimedOutAnnotation = "taweret.io/deletion-timed-out-at"
+	managedByLabel               = "app.kubernetes.io/managed-by"
+	managedByTaweret             = "taweret"
+)
+
+// how often the name of a deletion ActionSet can be taken by an ActionSet of another backup before giving up
+const maxDeletionNameConflicts = 5
+
+// the status of a backup whose deletion failed more often than the deletion max retries
+const statusDeletionFailed = "deletion_failed"
+
+// the longest backoff between retries of a failed deletion
+const maxDeletionRetryBackoff = 24 * time.Hour
+
+// deletionactionset is a deletion ActionSet created by Taweret for a backup
+type deletionactionset struct {
+	name       string
+	backupID   string
+	generation int
+	state      string
+	created    time.Time
+}
+
+// a deletion ActionSet without state has not been picked up by Kanister yet
+func (deletion deletionactionset) inProgress() bool {
+	return deletion.name != "" && deletion.state != string(v1alpha1.StateComplete) && deletion.state != string(v1alpha1.StateFailed)
+}
+
+// a deletion which Kanister did not pick up within the deletion timeout of the backup config is failed, so that it is retried
+func (deletion deletionactionset) withTimeout(backupConfig backupconfig, now time.Time) deletionactionset {
+	if deletion.name != "" && deletion.state == "" && !deletion.created.IsZero() && now.Sub(deletion.created) > backupConfig.deletionTimeout() {
+		deletion.state = string(v1alpha1.StateFailed)
+	}
+	return deletion
+}
+
+// records on a deletion ActionSet that waiting for it timed out, so that it is retried like a failed deletion
+func markDeletionTimedOut(deletion deletionactionset, dynamicClient dynamic.Interface, gvr schema.GroupVersionResource, backupConfig backupconfig, now time.Time) error {
+	patch, err := json.Marshal(map[string]interface{}{"metadata": map[string]interface{}{"annotations": map[string]interface{}{deletionTimedOutAnnotation: now.UTC().Format(time.RFC3339)}}})
+	if err != nil {
+		return err
+	}
+	_, err = dynamicClient.Resource(gvr).Namespace(backupConfig.KanisterNamespace).Patch(context.Background(), deletion.name, types.MergePatchType, patch, v1.PatchOptions{})
+	return err
+}
+
+// how often a failed deletion of the backup config is retried
+func (backupConfig backupconfig) deletionMaxRetries() int {
+	if backupConfig.DeletionMaxRetries != nil {
+		return int(*backupConfig.DeletionMaxRetries)
+	}
+	return *globalDeletionMaxRetries
+}
+
+// how long to wait before the first retry of a failed deletion of the backup config
+func (backupConfig backupconfig) deletionRetryBackoff() time.Duration {
+	if backoff, err := time.ParseDuration(backupConfig.DeletionRetryBackoff); err == nil && backoff > 0 {
+		return backoff
+	}
+	return *globalDeletionRetryBackoff
+}
+
+// whether the deletion failed and every retry has been used up
+func (deletion deletionactionset) retriesExhausted(backupConfig backupconfig) bool {
+	return deletion.state == string(v1alpha1.StateFailed) && deletion.generation >= backupConfig.deletionMaxRetries()
+}
+
+// when a failed deletion is retried, the backoff doubles with every failed retry. Zero if the deletion did not fail.
+func (deletion deletionactionset) retryAt(backupConfig backupconfig) time.Time {
+	if deletion.state != string(v1alpha1.StateFailed) {
+		return time.Time{}
+	}
+	backoff := backupConfig.deletionRetryBackoff()
+	for i := 0; i < deletion.generation && backoff < maxDeletionRetryBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > maxDeletionRetryBackoff {
+		backoff = maxDeletionRetryBackoff
+	}
+	return deletion.created.Add(backoff)
+}
+
+// records a warning Event on the backup ActionSet once its deletion failed for the last time
+func recordDeletionFailed(failedBackup backup, deletion deletionactionset, message string, dynamicClient dynamic.Interface, gvr schema.GroupVersionResource, backupConfig backupconfig) {
+	log.Printf("%v: deletion of backup %v failed %v times, giving up\n", backupConfig.Name, failedBackup.id(), deletion.generation+1)
+	actionset, err := dynamicClient.Resource(gvr).Namespace(backupConfig.KanisterNamespace).Get(context.Background(), failedBackup.name, v1.GetOptions{})
+	if err != nil {
+		log.Printf("%v: error retrieving backup actionset %v: %v\n", backupConfig.Name, failedBackup.name, err)
+		return
+	}
+	recordWarningEvent(actionset, eventReasonDeletionFailed, fmt.Sprintf("deleting backup %v failed %v times, last deletion actionset %v: %v", failedBackup.id(), deletion.generation+1, deletion.name, valueOrDefault(message, "unknown error")))
+}
+
+// returns a value as label value, or a hash of it if it is not a valid label value
+func labelValue(value string) string {
+	if len(validation.IsValidLabelValue(value)) == 0 {
+		return value
+	}
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(value)))[:validation.LabelValueMaxLength]
+}
+
+// the name of the deletion ActionSet of a backup, the first deletion keeps the name used by earlier versions of Taweret
+func deletionActionSetName(backupID string, generation int) string {
+	if generation == 0 {
+		return fmt.Sprintf("delete-%v", backupID)
+	}
+	return fmt.Sprintf("delete-%v-%v", backupID, generation)
+}
+
+// parses an ActionSet created by Taweret to delete a backup, the second return value is false for any other ActionSet
+func parseDeletionActionSet(actionset unstructured.Unstructured) (deletionactionset, bool) {
+	if actionset.GetLabels()[managedByLabel] != managedByTaweret {
+		return deletionactionset{}, false
+	}
+	backupID, ok := actionset.GetAnnotations()[deletesKey]
+	if !ok || labelValue(backupID) != actionset.GetLabels()[deletesKey] {
+		return deletionactionset{}, false
+	}
+	generation, _ := strconv.Atoi(actionset.GetAnnotations()[deletionGenerationAnnotation])
+	state, _ := actionSetState(&actionset)
+	// a deletion which timed out is failed, unless it finished after all
+	if _, timedOut := actionset.GetAnnotations()[deletionTimedOutAnnotation]; timedOut && state != string(v1alpha1.StateComplete) {
+		state = string(v1alpha1.StateFailed)
+	}
+	return deletionactionset{name: actionset.GetName(), backupID: backupID, generation: generation, state: state, created: actionset.GetCreationTimestamp().Time}, true
+}
+
+// parses a deletion ActionSet created by an earlier version of Taweret, which named it delete-<backup id> without labels. The second return
+// value is false unless its only action is the delete action of the blueprint of the backup config.
+func parseLegacyDeletionActionSet(actionset unstructured.Unstructured, backupID string, backupConfig backupconfig) (deletionactionset, bool) {
+	if _, ok := actionset.GetLabels()[managedByLabel]; ok || actionset.GetName() != deletionActionSetName(backupID, 0) {
+		return deletionactionset{}, false
+	}
+	var typedActionSet v1alpha1.ActionSet
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(actionset.Object, &typedActionSet); err != nil || typedActionSet.Spec == nil {
+		return deletionactionset{}, false
+	}
+	actions := typedActionSet.Spec.Actions
+	if len(actions) != 1 || actions[0].Name != backupConfig.deleteActionName() || actions[0].Blueprint != backupConfig.BlueprintName {
+		return deletionactionset{}, false
+	}
+	state, _ := actionSetState(&actionset)
+	return deletionactionset{name: actionset.GetName(), backupID: backupID, state: state, created: actionset.GetCreationTimestamp().Time}, true
+}
+
+// labels a legacy deletion ActionSet like the deletion ActionSets created by Taweret, so that it is found again after a restart
+func labelLegacyDeletionActionSet(deletion deletionactionset, dynamicClient dynamic.Interface, gvr schema.GroupVersionResource, backupConfig backupconfig) error {
+	patch, err := json.Marshal(map[string]interface{}{"metadata": map[string]interface{}{
+		"labels": map[string]interface{}{
+			managedByLabel:      managedByTaweret,
+			deletesKey:          labelValue(deletion.backupID),
+			backupConfigNameKey: labelValue(backupConfig.Name),
+		},
+		"annotations": map[string]interface{}{
+			deletesKey:                   deletion.backupID,
+			backupConfigNameKey:          backupConfig.Name,
+			deletionGenerationAnnotation: strconv.Itoa(deletion.generation),
+		},
+	}})
+	if err != nil {
+		return err
+	}
+	_, err = dynamicClient.Resource(gvr).Namespace(backupConfig.KanisterNamespace).Patch(context.Background(), deletion.name, types.MergePatchType, patch, v1.PatchOptions{})
+	return err
+}
+
+// returns the latest deletion ActionSet per backup id, i.e. the one with the highest generation
+func latestDeletions(actionsets []unstructured.Unstructured) map[string]deletionactionset {
+	deletions := map[string]deletionactionset{}
+	for _, actionset := range actionsets {
+		deletion, ok := parseDeletionActionSet(actionset)
+		if !ok {
+			continue
+		}
+		if latest, ok := deletions[deletion.backupID]; !ok || deletion.generation > latest.generation {
+			deletions[deletion.backupID] = deletion
+		}
+	}
+	return deletions
+}
+
+// constructs the deletion ActionSet of a backup, labelled with the backup it deletes
+func newDeletionActionSet(unusedBackup backup, backupConfig backupconfig, generation int) (*unstructured.Unstructured, error) {
+	deletionActionSet := v1alpha1.ActionSet{
+		Spec: &v1alpha1.ActionSetSpec{
+			Actions: []v1alpha1.ActionSpec{
+				{
+					Name:      backupConfig.deleteActionName(),
+					Blueprint: backupConfig.BlueprintName,
+					Artifacts: deletionArtifacts(unusedBackup, backupConfig),
+					Object: v1alpha1.ObjectReference{
+						Kind:      "namespace",
+						Name:      backupConfig.KanisterNamespace,
+						Namespace: backupConfig.KanisterNamespace,
+					},
+					Profile: &v1alpha1.ObjectReference{
+						Name:      backupConfig.ProfileName,
+						Namespace: backupConfig.KanisterNamespace,
+					},
+				},
+			},
+		},
+		TypeMeta: v1.TypeMeta{
+			APIVersion: "cr.kanister.io/v1alpha1",
+			Kind:       "ActionSet",
+		},
+		ObjectMeta: v1.ObjectMeta{
+			Name:      deletionActionSetName(unusedBackup.id(), generation),
+			Namespace: backupConfig.KanisterNamespace,
+			Labels: map[string]string{
+				managedByLabel:      managedByTaweret,
+				deletesKey:          labelValue(unusedBackup.id()),
+				backupConfigNameKey: labelValue(backupConfig.Name),
+			},
+			Annotations: map[string]string{
+				deletesKey:                   unusedBackup.id(),
+				backupConfigNameKey:          backupConfig.Name,
+				deletionGenerationAnnotation: strconv.Itoa(generation),
+			},
+		},
+	}
+
+	// convert to unstructured to apply with dynamicClient
+	object, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&deletionActionSet)
+	if err != nil {
+		return nil, fmt.Errorf("error converting deletion actionset: %w", err)
+	}
+	return &unstructured.Unstructured{Object: object}, nil
+}
+
+// returns the deletion ActionSet of a backup. A deletion ActionSet in progress or complete is adopted, including an unlabelled one
+// created by an earlier version of Taweret, otherwise a new one is created, with the next generation if the previous deletion failed.
+func ensureDeletionActionSet(unusedBackup backup, dynamicClient dynamic.Interface, gvr schema.GroupVersionResource, backupConfig backupconfig) (deletionactionset, error) {
+	actionsets := dynamicClient.Resource(gvr).Namespace(backupConfig.KanisterNamespace)
+
+	generation := 0
+	if unusedBackup.deletion.name != "" {
+		if unusedBackup.deletion.state != string(v1alpha1.StateFailed) {
+			log.Printf("%v: adopting deletion actionset %v of backup %v\n", backupConfig.Name, unusedBackup.deletion.name, unusedBackup.id())
+			return unusedBackup.deletion, nil
+		}
+		generation = unusedBackup.deletion.generation + 1
+	}
+
+	for conflicts := 0; conflicts < maxDeletionNameConflicts; generation++ {
+		deletionActionSet, err := newDeletionActionSet(unusedBackup, backupConfig, generation)
+		if err != nil {
+			return deletionactionset{}, err
+		}
+		name := deletionActionSet.GetName()
+
+		_, err = actionsets.Create(context.Background(), deletionActionSet, v1.CreateOptions{})
+		if err == nil {
+			log.Printf("%v: created deletion actionset %v of backup %v\n", backupConfig.Name, name, unusedBackup.id())
+			return deletionactionset{name: name, backupID: unusedBackup.id(), generation: generation}, nil
+		}
+		if !apierrors.IsAlreadyExists(err) {
+			return deletionactionset{}, fmt.Errorf("error creating deletion actionset %v: %w", name, err)
+		}
+
+		// the deletion actionset already exists, e.g. because it was created before a restart
+		existing, err := actionsets.Get(context.Background(), name, v1.GetOptions{})
+		if err != nil {
+			return deletionactionset{}, fmt.Errorf("error retrieving existing deletion actionset %v: %w", name, err)
+		}
+		deletion, ok := parseDeletionActionSet(*existing)
+		if !ok {
+			// a deletion actionset of an earlier version of Taweret is adopted as the first generation
+			if deletion, ok = parseLegacyDeletionActionSet(*existing, unusedBackup.id(), backupConfig); ok {
+				log.Printf("%v: labelling deletion actionset %v of backup %v created by an earlier version\n", backupConfig.Name, name, unusedBackup.id())
+				if err := labelLegacyDeletionActionSet(deletion, dynamicClient, gvr, backupConfig); err != nil {
+					return deletionactionset{}, fmt.Errorf("error labelling deletion actionset %v: %w", name, err)
+				}
+			}
+		}
+		deletion = deletion.withTimeout(backupConfig, time.Now())
+		switch {
+		case !ok || deletion.backupID != unusedBackup.id():
+			log.Printf("%v: actionset %v does not delete backup %v, trying the next generation\n", backupConfig.Name, name, unusedBackup.id())
+			conflicts++
+		case deletion.state == string(v1alpha1.StateFailed):
+			log.Printf("%v: deletion actionset %v of backup %v failed, retrying\n", backupConfig.Name, name, unusedBackup.id())
+		default:
+			log.Printf("%v: adopting deletion actionset %v of backup %v\n", backupConfig.Name, name, unusedBackup.id())
+			return deletion, nil
+		}
+	}
+	return deletionactionset{}, fmt.Errorf("error creating deletion actionset of backup %v: %v names are taken by other actionsets", unusedBackup.id(), maxDeletionNameConflicts)
+}
+
+// finishes deletions whose deletion ActionSet completed without the backup being pruned, e.g. because Taweret restarted while waiting.
+// Returns the remaining backups.
+func resumeDeletions(backups []backup, dynamicClient dynamic.Interface, gvr schema.GroupVersionResource, backupConfig backupconfig) ([]backup, error) {
+	var remaining []backup
+	for _, aBackup := range backups {
+		if aBackup.deletion.state != string(v1alpha1.StateComplete) {
+			remaining = append(remaining, aBackup)
+			continue
+		}
+		log.Printf("%v: deletion actionset %v of backup %v has completed, pruning the backup\n", backupConfig.Name, aBackup.deletion.name, aBackup.id())
+		if err := pruneBackupAction(aBackup, dynamicClient, gvr, backupConfig); err != nil {
+			return backups, err
+		}
+	}
+	return remaining, nil
+}
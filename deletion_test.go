@@ -0,0 +1,281 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation"
+	fake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/tools/record"
+)
+
+// returns a deletion actionset of a backup as created by Taweret, with the given state
+func newUnstructuredDeletion(t *testing.T, backupName string, backupConfig backupconfig, generation int, state string) *unstructured.Unstructured {
+	deletion, err := newDeletionActionSet(backup{name: backupName}, backupConfig, generation)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state != "" {
+		if err := unstructured.SetNestedField(deletion.Object, state, "status", "state"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return deletion
+}
+
+// returns a deletion actionset of a backup as created by earlier versions of Taweret, without labels
+func newLegacyDeletion(t *testing.T, backupName string, backupConfig backupconfig, state string) *unstructured.Unstructured {
+	deletion := newUnstructuredDeletion(t, backupName, backupConfig, 0, state)
+	deletion.SetLabels(nil)
+	deletion.SetAnnotations(nil)
+	return deletion
+}
+
+func TestDeletesLabelValue(t *testing.T) {
+	if value := labelValue("backup-1"); value != "backup-1" {
+		t.Fatalf("Expected a valid backup id to be used as label value, got %v", value)
+	}
+	longID := strings.Repeat("backup", 20)
+	value := labelValue(longID)
+	if value == longID || len(validation.IsValidLabelValue(value)) > 0 {
+		t.Fatalf("Expected a long backup id to be hashed into a valid label value, got %v", value)
+	}
+	if labelValue(longID) != value {
+		t.Fatal("Expected the hashed label value to be deterministic")
+	}
+}
+
+func TestEnsureDeletionActionSet(t *testing.T) {
+	gvr := schema.GroupVersionResource{Group: "cr.kanister.io", Version: "v1alpha1", Resource: "actionsets"}
+	var backupConfig backupconfig
+	backupConfig.Name = "daily"
+	backupConfig.KanisterNamespace = "kanister"
+
+	// the actionset delete-foreign belongs to someone else
+	foreign := newUnstructuredBackup("delete-foreign", "kanister", "2022-01-01T02:03:04Z", "backup", "weekly", "complete", "pg_backups/foreign/backup.sql.gz")
+	client := fake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{gvr: "ActionSetsList"},
+		newUnstructuredDeletion(t, "backup-running", backupConfig, 0, "running"),
+		newUnstructuredDeletion(t, "backup-failed", backupConfig, 0, "failed"),
+		newUnstructuredDeletion(t, "backup-failed", backupConfig, 1, "failed"),
+		newLegacyDeletion(t, "backup-legacy", backupConfig, "running"),
+		newLegacyDeletion(t, "backup-legacy-failed", backupConfig, "failed"),
+		foreign,
+	)
+
+	tests := []struct {
+		backup   backup
+		expected string
+	}{
+		// a new deletion keeps the name of earlier versions
+		{backup{name: "backup-new"}, "delete-backup-new"},
+		// a deletion in progress is adopted, even if it was not known
+		{backup{name: "backup-running"}, "delete-backup-running"},
+		// failed deletions are retried with the next generation
+		{backup{name: "backup-failed", deletion: deletionactionset{name: "delete-backup-failed", backupID: "backup-failed", state: "failed"}}, "delete-backup-failed-2"},
+		// an unlabelled deletion of an earlier version in progress is adopted instead of starting a second deletion
+		{backup{name: "backup-legacy"}, "delete-backup-legacy"},
+		// a failed deletion of an earlier version is the first generation
+		{backup{name: "backup-legacy-failed"}, "delete-backup-legacy-failed-1"},
+		// names taken by other actionsets are skipped
+		{backup{name: "foreign"}, "delete-foreign-1"},
+	}
+	for _, test := range tests {
+		deletion, err := ensureDeletionActionSet(test.backup, client, gvr, backupConfig)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if deletion.name != test.expected {
+			t.Fatalf("Expected the deletion actionset of %v to be %v, got %v", test.backup.name, test.expected, deletion.name)
+		}
+	}
+
+	legacy, err := client.Resource(gvr).Namespace("kanister").Get(context.Background(), "delete-backup-legacy", v1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deletion, ok := parseDeletionActionSet(*legacy); !ok || deletion.backupID != "backup-legacy" || deletion.generation != 0 || deletion.state != "running" {
+		t.Fatalf("Expected the adopted deletion actionset to be labelled as the first generation, got %+v", deletion)
+	}
+
+	created, err := client.Resource(gvr).Namespace("kanister").Get(context.Background(), "delete-backup-failed-2", v1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	deletion, ok := parseDeletionActionSet(*created)
+	if !ok || deletion.backupID != "backup-failed" || deletion.generation != 2 {
+		t.Fatalf("Expected the deletion actionset to be linked to its backup, got %+v", deletion)
+	}
+}
+
+func TestResumeDeletions(t *testing.T) {
+	gvr := schema.GroupVersionResource{Group: "cr.kanister.io", Version: "v1alpha1", Resource: "actionsets"}
+	var backupConfig backupconfig
+	backupConfig.Name = "daily"
+	backupConfig.KanisterNamespace = "kanister"
+
+	created := time.Now().AddDate(0, 0, -10).UTC().Format(time.RFC3339)
+	client := fake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{gvr: "ActionSetsList"},
+		newUnstructuredBackup("backup-deleted", "kanister", created, "backup", "daily", "complete", "pg_backups/deleted/backup.sql.gz"),
+		newUnstructuredBackup("backup-deleting", "kanister", created, "backup", "daily", "complete", "pg_backups/deleting/backup.sql.gz"),
+		newUnstructuredBackup("backup-failed", "kanister", created, "backup", "daily", "complete", "pg_backups/failed/backup.sql.gz"),
+		newUnstructuredDeletion(t, "backup-deleted", backupConfig, 0, "complete"),
+		newUnstructuredDeletion(t, "backup-deleting", backupConfig, 0, ""),
+		newUnstructuredDeletion(t, "backup-failed", backupConfig, 0, "failed"),
+	)
+
+	backups, _, err := getBackups(client, gvr, backupConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	statuses := map[string]string{}
+	for _, aBackup := range backups {
+		statuses[aBackup.name] = aBackup.status
+	}
+	if statuses["backup-deleted"] != "deleting" || statuses["backup-deleting"] != "deleting" || statuses["backup-failed"] != "complete" {
+		t.Fatalf("Expected the backups with a deletion in progress or complete to be deleting, got %v", statuses)
+	}
+
+	remaining, err := resumeDeletions(backups, client, gvr, backupConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(remaining) != 2 {
+		t.Fatalf("Expected the deleted backup to be pruned, got %+v", remaining)
+	}
+	if _, err := client.Resource(gvr).Namespace("kanister").Get(context.Background(), "backup-deleted", v1.GetOptions{}); !errors.IsNotFound(err) {
+		t.Fatalf("Expected the backup actionset of the completed deletion to be deleted, got %v", err)
+	}
+}
+
+func TestDeletionRetries(t *testing.T) {
+	var backupConfig backupconfig
+	backupConfig.Name = "daily"
+	backupConfig.DeletionRetryBackoff = "10m"
+	maxRetries := StringInt(2)
+	backupConfig.DeletionMaxRetries = &maxRetries
+
+	created := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		deletion  deletionactionset
+		retryAt   time.Time
+		exhausted bool
+	}{
+		{deletionactionset{name: "delete-a", state: "running", created: created}, time.Time{}, false},
+		{deletionactionset{name: "delete-a", state: "failed", created: created}, created.Add(10 * time.Minute), false},
+		{deletionactionset{name: "delete-a-1", state: "failed", generation: 1, created: created}, created.Add(20 * time.Minute), false},
+		{deletionactionset{name: "delete-a-2", state: "failed", generation: 2, created: created}, created.Add(40 * time.Minute), true},
+		{deletionactionset{name: "delete-a-20", state: "failed", generation: 20, created: created}, created.Add(maxDeletionRetryBackoff), true},
+	}
+	for _, test := range tests {
+		if retryAt := test.deletion.retryAt(backupConfig); !retryAt.Equal(test.retryAt) {
+			t.Fatalf("Expected %v to be retried at %v, got %v", test.deletion.name, test.retryAt, retryAt)
+		}
+		if exhausted := test.deletion.retriesExhausted(backupConfig); exhausted != test.exhausted {
+			t.Fatalf("Expected the retries of %v to be exhausted: %v, got %v", test.deletion.name, test.exhausted, exhausted)
+		}
+	}
+}
+
+func TestFailedDeletion(t *testing.T) {
+	recorder := record.NewFakeRecorder(1)
+	defer func(previous record.EventRecorder) { eventRecorder = previous }(eventRecorder)
+	eventRecorder = recorder
+
+	gvr := schema.GroupVersionResource{Group: "cr.kanister.io", Version: "v1alpha1", Resource: "actionsets"}
+	var backupConfig backupconfig
+	backupConfig.Name = "daily"
+	backupConfig.KanisterNamespace = "kanister"
+	backupConfig.DeletionTimeout = "5s"
+	maxRetries := StringInt(0)
+	backupConfig.DeletionMaxRetries = &maxRetries
+
+	created := time.Now().AddDate(0, 0, -10).UTC().Format(time.RFC3339)
+	client := fake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{gvr: "ActionSetsList"},
+		newUnstructuredBackup("backup-old", "kanister", created, "backup", "daily", "complete", "pg_backups/old/backup.sql.gz"),
+	)
+
+	// kanister fails the deletion
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		failed := newUnstructuredDeletion(t, "backup-old", backupConfig, 0, "failed")
+		failed.Object["status"].(map[string]interface{})["error"] = map[string]interface{}{"message": "access denied"}
+		client.Resource(gvr).Namespace("kanister").Update(context.Background(), failed, v1.UpdateOptions{})
+	}()
+	outcome, err := deleteBackup(backup{name: "backup-old", status: "complete"}, client, gvr, backupConfig)
+	if err != nil || outcome != deletionOutcomeFailed {
+		t.Fatalf("Expected the deletion to fail, got %v, %v", outcome, err)
+	}
+
+	select {
+	case event := <-recorder.Events:
+		if !strings.Contains(event, eventReasonDeletionFailed) || !strings.Contains(event, "access denied") {
+			t.Fatalf("Unexpected event: %v", event)
+		}
+	default:
+		t.Fatal("Expected an event on the backup actionset")
+	}
+
+	// the backup actionset is kept and reported as deletion_failed
+	backups, _, err := getBackups(client, gvr, backupConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backups) != 1 || backups[0].status != statusDeletionFailed {
+		t.Fatalf("Expected the backup to be kept as %v, got %+v", statusDeletionFailed, backups)
+	}
+	_, _, backupCounts := categoriseBackups(backups, backupConfig)
+	if backupCounts.deletionFailed != 1 {
+		t.Fatalf("Expected one backup whose deletion failed, got %+v", backupCounts)
+	}
+}
+
+func TestTimedOutDeletion(t *testing.T) {
+	gvr := schema.GroupVersionResource{Group: "cr.kanister.io", Version: "v1alpha1", Resource: "actionsets"}
+	var backupConfig backupconfig
+	backupConfig.Name = "daily"
+	backupConfig.KanisterNamespace = "kanister"
+	backupConfig.DeletionTimeout = "1s"
+
+	created := time.Now().AddDate(0, 0, -10).UTC().Format(time.RFC3339)
+	// kanister never picked up the deletion of backup-stale
+	stale := newUnstructuredDeletion(t, "backup-stale", backupConfig, 0, "")
+	stale.SetCreationTimestamp(v1.NewTime(time.Now().Add(-time.Hour)))
+	client := fake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{gvr: "ActionSetsList"},
+		newUnstructuredBackup("backup-a", "kanister", created, "backup", "daily", "complete", "pg_backups/a/backup.sql.gz"),
+		newUnstructuredBackup("backup-b", "kanister", created, "backup", "daily", "complete", "pg_backups/b/backup.sql.gz"),
+		newUnstructuredBackup("backup-stale", "kanister", created, "backup", "daily", "complete", "pg_backups/stale/backup.sql.gz"),
+		stale,
+	)
+
+	// a deletion which times out does not stop the deletion of the remaining backups
+	plan := []planneddeletion{{backup: backup{name: "backup-a", status: "complete"}}, {backup: backup{name: "backup-b", status: "complete"}}}
+	deleted, err := deletePlannedBackups(plan, client, gvr, newTaweretMetrics(), backupConfig)
+	if err != nil || deleted != 0 {
+		t.Fatalf("Expected both deletions to time out without an error, got %v, %v", deleted, err)
+	}
+
+	// the timed out and the stale deletions are failed, so that they are retried instead of deleting forever
+	backups, _, err := getBackups(client, gvr, backupConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backups) != 3 {
+		t.Fatalf("Expected the backups to be kept, got %+v", backups)
+	}
+	for _, aBackup := range backups {
+		if aBackup.status != "complete" || aBackup.deletion.state != "failed" {
+			t.Fatalf("Expected the deletion of %v to be failed and the backup to be complete, got %v, %+v", aBackup.name, aBackup.status, aBackup.deletion)
+		}
+	}
+	deletion, err := ensureDeletionActionSet(backups[0], client, gvr, backupConfig)
+	if err != nil || deletion.generation != 1 {
+		t.Fatalf("Expected the timed out deletion to be retried with the next generation, got %+v, %v", deletion, err)
+	}
+}
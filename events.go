@@ -0,0 +1,34 @@
+package main
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+// event reasons
+const (
+	eventReasonInvalidBackupConfig = "InvalidBackupConfig"
+	eventReasonDeletionFailed      = "DeletionFailed"
+)
+
+// eventRecorder records Kubernetes Events, nil when Events are not recorded, e.g. in the command line interface
+var eventRecorder record.EventRecorder
+
+// creates the event recorder which records Kubernetes Events as the taweret component
+func initialiseEventRecorder(clientSet kubernetes.Interface) {
+	broadcaster := record.NewBroadcaster()
+	broadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{Interface: clientSet.CoreV1().Events("")})
+	eventRecorder = broadcaster.NewRecorder(scheme.Scheme, corev1.EventSource{Component: "taweret"})
+}
+
+// records a warning Event on an object, if Events are recorded
+func recordWarningEvent(object runtime.Object, reason string, message string) {
+	if eventRecorder == nil || object == nil {
+		return
+	}
+	eventRecorder.Event(object, corev1.EventTypeWarning, reason, message)
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// a backup ActionSet with the hold label or annotation is never pruned, unless the hold expires at the time of the hold-until annotation
+const (
+	holdKey             = "taweret.io/hold"
+	holdUntilAnnotation = "taweret.io/hold-until"
+)
+
+// reads the hold of a backup ActionSet from its labels and annotations. A hold-until annotation which cannot be parsed is returned
+// as an error, and the backup is held indefinitely.
+func parseHold(labels map[string]string, annotations map[string]string) (bool, time.Time, error) {
+	value, ok := labels[holdKey]
+	if !ok {
+		value, ok = annotations[holdKey]
+	}
+	if !ok || value == "false" {
+		return false, time.Time{}, nil
+	}
+
+	until, ok := annotations[holdUntilAnnotation]
+	if !ok {
+		return true, time.Time{}, nil
+	}
+	holdUntil, err := time.Parse(time.RFC3339, until)
+	if err != nil {
+		return true, time.Time{}, fmt.Errorf("invalid %v annotation %v, holding indefinitely: %w", holdUntilAnnotation, until, err)
+	}
+	return true, holdUntil, nil
+}
+
+// whether the backup is held at the given time
+func (b backup) isHeld(now time.Time) bool {
+	return b.held && (b.holdUntil.IsZero() || now.Before(b.holdUntil))
+}
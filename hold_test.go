@@ -0,0 +1,53 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseHold(t *testing.T) {
+	held, holdUntil, err := parseHold(map[string]string{holdKey: "true"}, nil)
+	if err != nil || !held || !holdUntil.IsZero() {
+		t.Fatalf("Expected the label to hold the backup indefinitely, got %v %v %v", held, holdUntil, err)
+	}
+
+	held, holdUntil, err = parseHold(nil, map[string]string{holdKey: "incident-42", holdUntilAnnotation: "2030-01-01T00:00:00Z"})
+	if err != nil || !held || !holdUntil.Equal(time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("Expected the annotation to hold the backup until 2030, got %v %v %v", held, holdUntil, err)
+	}
+
+	held, _, err = parseHold(map[string]string{holdKey: "true"}, map[string]string{holdUntilAnnotation: "next week"})
+	if err == nil || !held {
+		t.Fatalf("Expected an invalid expiry to be reported and the backup to be held, got %v %v", held, err)
+	}
+
+	if held, _, _ = parseHold(map[string]string{holdKey: "false"}, nil); held {
+		t.Fatal("Expected a false hold not to hold the backup")
+	}
+}
+
+func TestCategoriseHeldBackups(t *testing.T) {
+	now := time.Now()
+	backups := []backup{
+		{name: "backup-new", status: "complete", time: now.Add(-1 * time.Hour)},
+		{name: "backup-held", status: "complete", time: now.AddDate(0, 0, -20), held: true},
+		{name: "backup-hold-expired", status: "complete", time: now.AddDate(0, 0, -20), held: true, holdUntil: now.Add(-1 * time.Hour)},
+		{name: "backup-held-until", status: "complete", time: now.AddDate(0, 0, -1), held: true, holdUntil: now.Add(time.Hour)},
+	}
+
+	var backupConfig backupconfig
+	backupConfig.Name = "daily"
+	backupConfig.Retention.Backups = 1
+	backupConfig.Retention.Days = 7
+
+	categorisedBackups, expiredBackups, backupCounts := categoriseBackups(backups, backupConfig)
+	if backupCounts.held != 2 {
+		t.Fatalf("Expected two held backups, got %v", backupCounts.held)
+	}
+	if len(categorisedBackups) != 1 || categorisedBackups[0].name != "backup-new" {
+		t.Fatalf("Expected held backups not to count towards the backups limit, got %v", categorisedBackups)
+	}
+	if len(expiredBackups) != 1 || expiredBackups[0].name != "backup-hold-expired" {
+		t.Fatalf("Expected only the backup whose hold expired to expire, got %v", expiredBackups)
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/kanisterio/kanister/pkg/apis/cr/v1alpha1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+)
+
+// how long a finished deletion ActionSet is kept after its creation, failed ones are kept longer for debugging
+func deletionActionSetTTLs() map[string]time.Duration {
+	return map[string]time.Duration{
+		string(v1alpha1.StateComplete): *deletionActionSetTTL,
+		string(v1alpha1.StateFailed):   *failedDeletionActionSetTTL,
+	}
+}
+
+// garbage-collects the finished deletion ActionSets of a backup config once their TTL has passed, including the unlabelled ones created
+// by earlier versions of Taweret with the delete action of the blueprint of the backup config. The latest deletion ActionSet of a backup
+// which still exists is kept, as it records how often the deletion of the backup failed.
+func collectDeletionActionSets(backups []backup, dynamicClient dynamic.Interface, gvr schema.GroupVersionResource, taweretMetrics taweretmetrics, backupConfig backupconfig, now time.Time) error {
+	ttls := deletionActionSetTTLs()
+	if ttls[string(v1alpha1.StateComplete)] <= 0 && ttls[string(v1alpha1.StateFailed)] <= 0 {
+		return nil
+	}
+
+	// the deletion actionsets which still belong to a backup
+	current := map[string]bool{}
+	for _, aBackup := range backups {
+		if aBackup.deletion.name != "" {
+			current[aBackup.deletion.name] = true
+		}
+	}
+
+	actionsets := dynamicClient.Resource(gvr).Namespace(backupConfig.KanisterNamespace)
+	actionsetList, err := actionsets.List(context.Background(), v1.ListOptions{})
+	if err != nil {
+		return fmt.Errorf("error getting actionsets: %w", err)
+	}
+
+	// the ids of every backup which still exists in the namespace, whatever its backup config, as other backup configs may use the same
+	// blueprint
+	existing := map[string]bool{}
+	for _, actionset := range actionsetList.Items {
+		existing[actionset.GetName()] = true
+		actions, _, _ := unstructured.NestedSlice(actionset.Object, "spec", "actions")
+		for action := range actions {
+			existing[fmt.Sprintf("%v-%v", actionset.GetName(), action)] = true
+		}
+	}
+
+	var candidates []deletionactionset
+	for _, actionset := range actionsetList.Items {
+		if _, managed := actionset.GetLabels()[managedByLabel]; managed {
+			deletion, ok := parseDeletionActionSet(actionset)
+			deletion = deletion.withTimeout(backupConfig, now)
+			if ok && actionset.GetAnnotations()[backupConfigNameKey] == backupConfig.Name && !current[deletion.name] {
+				candidates = append(candidates, deletion)
+			}
+			continue
+		}
+
+		// the unlabelled deletion actionsets created by earlier versions of Taweret, a deletion of a backup which still exists is labelled
+		// and adopted when the backup is deleted again
+		backupID := strings.TrimPrefix(actionset.GetName(), "delete-")
+		if backupID == actionset.GetName() || existing[backupID] {
+			continue
+		}
+		if deletion, ok := parseLegacyDeletionActionSet(actionset, backupID, backupConfig); ok {
+			candidates = append(candidates, deletion)
+		}
+	}
+
+	for _, deletion := range candidates {
+		ttl, ok := ttls[deletion.state]
+		if !ok || ttl <= 0 || now.Sub(deletion.created) < ttl {
+			continue
+		}
+
+		log.Printf("%v: garbage-collecting %v deletion actionset %v of backup %v\n", backupConfig.Name, deletion.state, deletion.name, deletion.backupID)
+		if err := actionsets.Delete(context.Background(), deletion.name, v1.DeleteOptions{}); err != nil && !apierrors.IsNotFound(err) {
+			return fmt.Errorf("error deleting deletion actionset %v: %w", deletion.name, err)
+		}
+		taweretMetrics.collectedActionSets.WithLabelValues(backupConfig.Name, deletion.state).Inc()
+	}
+	return nil
+}
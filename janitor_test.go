@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"k8s.io/apimachinery/pkg/api/errors"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	fake "k8s.io/client-go/dynamic/fake"
+)
+
+func TestCollectDeletionActionSets(t *testing.T) {
+	gvr := schema.GroupVersionResource{Group: "cr.kanister.io", Version: "v1alpha1", Resource: "actionsets"}
+	now := time.Now()
+	var backupConfig backupconfig
+	backupConfig.Name = "daily"
+	backupConfig.KanisterNamespace = "kanister"
+	var otherConfig backupconfig
+	otherConfig.Name = "weekly"
+	otherConfig.KanisterNamespace = "kanister"
+
+	deletions := []struct {
+		backup     string
+		config     backupconfig
+		generation int
+		state      string
+		age        time.Duration
+		collected  bool
+	}{
+		{"backup-deleted", backupConfig, 0, "complete", 48 * time.Hour, true},
+		{"backup-recent", backupConfig, 0, "complete", time.Hour, false},
+		{"backup-failed-recently", backupConfig, 0, "failed", 48 * time.Hour, false},
+		{"backup-failed-long-ago", backupConfig, 0, "failed", 8 * 24 * time.Hour, true},
+		{"backup-retrying", backupConfig, 0, "failed", 9 * 24 * time.Hour, true},
+		{"backup-retrying", backupConfig, 1, "failed", 8 * 24 * time.Hour, false},
+		{"backup-running", backupConfig, 0, "running", 48 * time.Hour, false},
+		{"backup-weekly", otherConfig, 0, "complete", 48 * time.Hour, false},
+	}
+	var objects []runtime.Object
+	for _, deletion := range deletions {
+		actionset := newUnstructuredDeletion(t, deletion.backup, deletion.config, deletion.generation, deletion.state)
+		actionset.SetCreationTimestamp(v1.NewTime(now.Add(-deletion.age)))
+		objects = append(objects, actionset)
+	}
+
+	// unlabelled deletion actionsets of earlier versions, backup-legacy-retrying and the backup of the weekly config with the same blueprint
+	// still exist, and another blueprint deleted backup-other
+	otherBlueprint := backupConfig
+	otherBlueprint.BlueprintName = "mysql-bp"
+	legacyDeletions := []struct {
+		backup    string
+		config    backupconfig
+		state     string
+		collected bool
+	}{
+		{"backup-legacy", backupConfig, "complete", true},
+		{"backup-legacy-failed", backupConfig, "failed", true},
+		{"backup-legacy-running", backupConfig, "running", false},
+		{"backup-legacy-retrying", backupConfig, "failed", false},
+		{"backup-legacy-weekly", backupConfig, "complete", false},
+		{"backup-other", otherBlueprint, "complete", false},
+	}
+	for _, deletion := range legacyDeletions {
+		actionset := newLegacyDeletion(t, deletion.backup, deletion.config, deletion.state)
+		actionset.SetCreationTimestamp(v1.NewTime(now.Add(-30 * 24 * time.Hour)))
+		objects = append(objects, actionset)
+	}
+	created := now.Add(-30 * 24 * time.Hour).UTC().Format(time.RFC3339)
+	objects = append(objects,
+		newUnstructuredBackup("backup-legacy-retrying", "kanister", created, "backup", "daily", "complete", "pg_backups/retrying/backup.sql.gz"),
+		newUnstructuredBackup("backup-legacy-weekly", "kanister", created, "backup", "weekly", "complete", "pg_backups/weekly/backup.sql.gz"),
+	)
+	client := fake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{gvr: "ActionSetsList"}, objects...)
+
+	// only backup-retrying and backup-legacy-retrying still exist, the latest deletion of backup-retrying records the failed retries
+	backups := []backup{
+		{name: "backup-retrying", status: "complete", deletion: deletionactionset{name: "delete-backup-retrying-1", backupID: "backup-retrying", generation: 1, state: "failed"}},
+		{name: "backup-legacy-retrying", status: "complete"},
+	}
+	taweretMetrics := newTaweretMetrics()
+	if err := collectDeletionActionSets(backups, client, gvr, taweretMetrics, backupConfig, now); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, deletion := range deletions {
+		name := deletionActionSetName(deletion.backup, deletion.generation)
+		_, err := client.Resource(gvr).Namespace("kanister").Get(context.Background(), name, v1.GetOptions{})
+		if deletion.collected != errors.IsNotFound(err) {
+			t.Fatalf("Expected %v to be collected: %v, got %v", name, deletion.collected, err)
+		}
+	}
+	for _, deletion := range legacyDeletions {
+		name := deletionActionSetName(deletion.backup, 0)
+		_, err := client.Resource(gvr).Namespace("kanister").Get(context.Background(), name, v1.GetOptions{})
+		if deletion.collected != errors.IsNotFound(err) {
+			t.Fatalf("Expected the deletion actionset %v of an earlier version to be collected: %v, got %v", name, deletion.collected, err)
+		}
+	}
+	if collected := testutil.ToFloat64(taweretMetrics.collectedActionSets.WithLabelValues("daily", "complete")); collected != 2 {
+		t.Fatalf("Expected two collected complete deletion actionsets, got %v", collected)
+	}
+	if collected := testutil.ToFloat64(taweretMetrics.collectedActionSets.WithLabelValues("daily", "failed")); collected != 3 {
+		t.Fatalf("Expected three collected failed deletion actionsets, got %v", collected)
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+// lease timings, a new leader is elected at most leaseDuration after the previous leader stopped renewing the lease
+const (
+	leaseDuration = 15 * time.Second
+	renewDeadline = 10 * time.Second
+	retryPeriod   = 2 * time.Second
+)
+
+// the leader election state of the replica, used by the health endpoint
+var leaderHealth = leaderelection.NewLeaderHealthzAdaptor(20 * time.Second)
+
+// creates a leader elector on the Lease with the given name. onStartedLeading runs while the replica is the leader, its context is cancelled when the lease is lost.
+func newLeaderElector(clientSet kubernetes.Interface, namespace string, name string, identity string, onStartedLeading func(context.Context)) (*leaderelection.LeaderElector, error) {
+	lock := &resourcelock.LeaseLock{
+		LeaseMeta:  v1.ObjectMeta{Namespace: namespace, Name: name},
+		Client:     clientSet.CoordinationV1(),
+		LockConfig: resourcelock.ResourceLockConfig{Identity: identity},
+	}
+
+	return leaderelection.NewLeaderElector(leaderelection.LeaderElectionConfig{
+		Lock:            lock,
+		LeaseDuration:   leaseDuration,
+		RenewDeadline:   renewDeadline,
+		RetryPeriod:     retryPeriod,
+		ReleaseOnCancel: true,
+		WatchDog:        leaderHealth,
+		Name:            name,
+		Callbacks: leaderelection.LeaderCallbacks{
+			OnStartedLeading: onStartedLeading,
+			OnStoppedLeading: func() {
+				log.Printf("%v: no longer the leader\n", identity)
+			},
+			OnNewLeader: func(leader string) {
+				log.Printf("leader elected: %v\n", leader)
+			},
+		},
+	})
+}
+
+// runs evaluations only while this replica holds the leader lease, the context passed to evaluate is cancelled when the lease is lost.
+// Losing the lease also exits the process, so that evaluations and deletions of a former leader never overlap with the new leader.
+func runLeaderElection(clientSet kubernetes.Interface, namespace string, name string, evaluate func(context.Context)) {
+	identity, err := os.Hostname()
+	if err != nil {
+		log.Fatalf("error getting the leader election identity: %v", err)
+	}
+
+	leaderElector, err := newLeaderElector(clientSet, namespace, name, identity, func(ctx context.Context) {
+		log.Printf("%v: became the leader, scheduling evaluations\n", identity)
+		evaluate(ctx)
+	})
+	if err != nil {
+		log.Fatalf("error creating the leader elector: %v", err)
+	}
+
+	leaderElector.Run(context.Background())
+	log.Fatalf("%v: lost the leader lease %v/%v, exiting", identity, namespace, name)
+}
+
+// serves the health of the replica, a leader which failed to renew its lease is unhealthy
+func healthHandler(w http.ResponseWriter, r *http.Request) {
+	if err := leaderHealth.Check(r); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	fmt.Fprintln(w, "ok")
+}
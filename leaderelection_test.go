@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kubernetesfake "k8s.io/client-go/kubernetes/fake"
+)
+
+func TestLeaderElection(t *testing.T) {
+	clientSet := kubernetesfake.NewSimpleClientset()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	leading := make(chan struct{})
+	leaderElector, err := newLeaderElector(clientSet, "kanister", "taweret", "taweret-0", func(ctx context.Context) {
+		close(leading)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	go leaderElector.Run(ctx)
+
+	select {
+	case <-leading:
+	case <-time.After(10 * time.Second):
+		t.Fatal("Expected the only replica to become the leader")
+	}
+
+	lease, err := clientSet.CoordinationV1().Leases("kanister").Get(context.Background(), "taweret", v1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lease.Spec.HolderIdentity == nil || *lease.Spec.HolderIdentity != "taweret-0" {
+		t.Fatalf("Expected the lease to be held by taweret-0, got %v", lease.Spec.HolderIdentity)
+	}
+
+	// a second replica does not become the leader while the lease is held
+	follower, err := newLeaderElector(clientSet, "kanister", "taweret", "taweret-1", func(ctx context.Context) {
+		t.Error("Expected the second replica not to become the leader")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	followerCtx, followerCancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer followerCancel()
+	follower.Run(followerCtx)
+	if follower.IsLeader() {
+		t.Fatal("Expected taweret-1 to be a follower")
+	}
+}
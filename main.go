@@ -3,32 +3,49 @@ package main
 
 import (
 	"context"
+	"errors"
+	"flag"
 	"fmt"
 	"log"
+	"math"
 	"net/http"
 	"os"
 	"sort"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
 
-	"github.com/go-co-op/gocron"
 	"github.com/kanisterio/kanister/pkg/apis/cr/v1alpha1"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	taweretv1alpha1 "github.com/swissdatasciencecenter/taweret/pkg/apis/taweret/v1alpha1"
 	"gopkg.in/yaml.v2"
+	corev1 "k8s.io/api/core/v1"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
 )
 
 type backup struct {
 	name, schedule, status, backupLocation string
 	time                                   time.Time
 	inUse                                  bool
+	// the index of the backup action in the ActionSet, and the number of backup actions in the ActionSet
+	action, actionCount int
+	// every artifact of the backup action, passed to the deletion actionset
+	artifacts map[string]v1alpha1.Artifact
+	// a held backup is never pruned, until the hold expires if holdUntil is set
+	held      bool
+	holdUntil time.Time
+	// when the backup actionset was marked for deletion, zero if it is not marked
+	markedForDeletionAt time.Time
+	// the latest deletion actionset of the backup, empty if it has none
+	deletion deletionactionset
 }
 
 type backupconfig struct {
@@ -36,6 +53,13 @@ type backupconfig struct {
 	KanisterNamespace string `yaml:"kanisterNamespace"`
 	BlueprintName     string `yaml:"blueprintName"`
 	ProfileName       string `yaml:"profileName"`
+	// the action, option and artifact names used by the blueprint, empty values default to the names of the stock postgres blueprint
+	BackupActionName  string            `yaml:"backupActionName"`
+	DeleteActionName  string            `yaml:"deleteActionName"`
+	ScheduleOptionKey string            `yaml:"scheduleOptionKey"`
+	ArtifactName      string            `yaml:"artifactName"`
+	ArtifactKey       string            `yaml:"artifactKey"`
+	ArtifactMapping   map[string]string `yaml:"artifactMapping"`
 	Retention         struct {
 		Backups StringInt `yaml:"backups"`
 		Minutes StringInt `yaml:"minutes"`
@@ -43,9 +67,94 @@ type backupconfig struct {
 		Days    StringInt `yaml:"days"`
 		Months  StringInt `yaml:"months"`
 		Years   StringInt `yaml:"years"`
+		// grandfather-father-son retention buckets, see retention.go
+		KeepHourly  StringInt `yaml:"keepHourly"`
+		KeepDaily   StringInt `yaml:"keepDaily"`
+		KeepWeekly  StringInt `yaml:"keepWeekly"`
+		KeepMonthly StringInt `yaml:"keepMonthly"`
+		KeepYearly  StringInt `yaml:"keepYearly"`
+		// backups: 0 deletes every backup which is not retained otherwise, and is rejected unless explicitly allowed
+		AllowZeroBackups bool `yaml:"allowZeroBackups"`
+		// the number of failed and skipped backup actionsets kept for debugging, all are kept if unset, see cleanup.go
+		KeepFailed  *StringInt `yaml:"keepFailed"`
+		KeepSkipped *StringInt `yaml:"keepSkipped"`
 	}
+	// how long a backup actionset can be pending before it is reported as stale, e.g. 6h, defaults to the stale-pending-after flag
+	StalePendingAfter string `yaml:"stalePendingAfter"`
+	// how often complete backups are expected, either every interval, e.g. 24h, or on a cron schedule in the timezone, see rpo.go
+	ExpectedBackups struct {
+		Interval  string `yaml:"interval"`
+		Schedule  string `yaml:"schedule"`
+		Tolerance string `yaml:"tolerance"`
+	} `yaml:"expectedBackups"`
+	// safeguards: the minimum number of complete backups, and the age of the newest complete backup after which nothing is deleted, e.g. 48h
+	MinKeep            StringInt `yaml:"minKeep"`
+	NewestBackupMaxAge string    `yaml:"newestBackupMaxAge"`
+	// only report which backups would be deleted, without deleting them
+	DryRun bool `yaml:"dryRun"`
+	// how long to wait for a deletion actionset, e.g. 45m, defaults to the deletion-timeout flag
+	DeletionTimeout string `yaml:"deletionTimeout"`
+	// how long backups are marked for deletion before they are deleted, e.g. 72h, defaults to the deletion-grace-period flag
+	DeletionGracePeriod string `yaml:"deletionGracePeriod"`
+	// how often a failed deletion is retried and the backoff before the first retry, e.g. 10m, default to the deletion-max-retries and deletion-retry-backoff flags
+	DeletionMaxRetries   *StringInt `yaml:"deletionMaxRetries"`
+	DeletionRetryBackoff string     `yaml:"deletionRetryBackoff"`
+	// when the config is evaluated, a cron expression in the timezone, e.g. Europe/Zurich, defaults to the evaluation-schedule and timezone flags
+	EvaluationSchedule string `yaml:"evaluationSchedule"`
+	Timezone           string `yaml:"timezone"`
+	// the BackupPolicy the config was read from, nil for configs read from ConfigMaps
+	policy *taweretv1alpha1.BackupPolicy
+	// the object the config was read from and its source, e.g. configmap/<namespace>/<name>
+	object runtime.Object
+	source string
 }
 
+// the default namespace in which backup configs are defined
+const configNamespace string = "kanister"
+
+// the config-namespaces value which watches backup configs in all namespaces
+const allNamespaces string = "*"
+
+// command line flags
+var (
+	// where backup configs are discovered
+	configNamespaces = flag.String("config-namespaces", configNamespace, "comma separated namespaces in which backup configs are defined, * for all namespaces")
+	configSelector   = flag.String("config-selector", "", "label selector of the ConfigMaps which define backup configs, e.g. taweret.io/backup-config=true, by default every ConfigMap with a backup-config.yaml key")
+	// safeguards for all backup configs
+	globalMinKeep            = flag.Int("min-keep", 1, "the minimum number of complete backups every backup config keeps, whatever its retention")
+	globalNewestBackupMaxAge = flag.Duration("newest-backup-max-age", 0, "withhold all deletions of a backup config whose newest complete backup is older than this, unless the backup config sets newestBackupMaxAge, 0 disables the safeguard")
+	// dry run mode for all backup configs
+	globalDryRun = flag.Bool("dry-run", false, "only report which backups would be deleted, without deleting any backups")
+	// the kubeconfig and context used outside of the cluster
+	kubeconfigPath = flag.String("kubeconfig", "", "path to a kubeconfig file, by default the KUBECONFIG environment variable, ~/.kube/config or the in-cluster config are used")
+	kubeContext    = flag.String("context", "", "the kubeconfig context to use, by default the current context")
+	// run a single evaluation instead of scheduling evaluations
+	once          = flag.Bool("once", false, "evaluate all backup configs once and exit")
+	listenAddress = flag.String("listen-address", ":2112", "the address on which metrics and deletion plans are served")
+	// how many backup configs are evaluated and how many deletion actionsets run at the same time
+	concurrency          = flag.Int("concurrency", 4, "the maximum number of backup configs evaluated in parallel")
+	maxInflightDeletions = flag.Int("max-inflight-deletions", 4, "the maximum number of deletion actionsets running at the same time across all backup configs")
+	// how long to wait for a deletion actionset before giving up
+	globalDeletionTimeout = flag.Duration("deletion-timeout", 30*time.Minute, "how long to wait for a deletion actionset to complete, unless a backup config sets deletionTimeout")
+	// the default evaluation schedule of backup configs
+	globalEvaluationSchedule = flag.String("evaluation-schedule", "* * * * *", "the cron expression on which backup configs are evaluated, unless a backup config sets evaluationSchedule")
+	globalTimezone           = flag.String("timezone", "UTC", "the timezone of evaluation schedules, unless a backup config sets timezone")
+	// how long backups are marked for deletion before they are deleted
+	globalDeletionGracePeriod = flag.Duration("deletion-grace-period", 0, "mark backups for deletion and only delete them after this grace period, unless a backup config sets deletionGracePeriod, 0 deletes backups immediately")
+	// how long finished deletion actionsets are kept
+	deletionActionSetTTL       = flag.Duration("deletion-actionset-ttl", 24*time.Hour, "how long complete deletion actionsets are kept before they are garbage-collected, 0 keeps them")
+	failedDeletionActionSetTTL = flag.Duration("failed-deletion-actionset-ttl", 7*24*time.Hour, "how long failed deletion actionsets are kept for debugging before they are garbage-collected, 0 keeps them")
+	// when pending backup actionsets are reported as stale
+	globalStalePendingAfter = flag.Duration("stale-pending-after", 24*time.Hour, "report backup actionsets which have been pending for longer than this as stale, unless a backup config sets stalePendingAfter, 0 disables the report")
+	// how failed deletions are retried
+	globalDeletionMaxRetries   = flag.Int("deletion-max-retries", 5, "how often a failed deletion is retried before the backup is reported as deletion_failed, unless a backup config sets deletionMaxRetries")
+	globalDeletionRetryBackoff = flag.Duration("deletion-retry-backoff", 10*time.Minute, "how long to wait before retrying a failed deletion, doubled after every failed retry, unless a backup config sets deletionRetryBackoff")
+	// only the replica holding the leader lease evaluates backup configs
+	leaderElect             = flag.Bool("leader-elect", false, "elect a leader with a Lease so that only one of several replicas evaluates backup configs")
+	leaderElectionNamespace = flag.String("leader-election-namespace", configNamespace, "the namespace of the leader election Lease")
+	leaderElectionID        = flag.String("leader-election-id", "taweret", "the name of the leader election Lease")
+)
+
 // StringInt is a type for custom YAML unmarshalling
 type StringInt int
 
@@ -53,6 +162,46 @@ type taweretmetrics struct {
 	backupCount  *prometheus.GaugeVec
 	oldestBackup *prometheus.GaugeVec
 	newestBackup *prometheus.GaugeVec
+	// whether an expected backup is missing per backup config, and for how long
+	rpoViolation   *prometheus.GaugeVec
+	overdueSeconds *prometheus.GaugeVec
+	// finished deletions per backup config and outcome
+	deletions *prometheus.CounterVec
+	// garbage-collected deletion ActionSets per backup config and state
+	collectedActionSets *prometheus.CounterVec
+	// cleaned up failed and skipped backup ActionSets per backup config and state, and stale pending backup ActionSets per backup config
+	cleanedActionSets      *prometheus.CounterVec
+	stalePendingActionSets *prometheus.GaugeVec
+	// failed evaluations per backup config
+	evaluationErrors *prometheus.CounterVec
+	// backup configs which could not be read per source
+	configErrors *prometheus.CounterVec
+	// ActionSets which could not be parsed per backup config and reason
+	skippedActionSets *prometheus.GaugeVec
+	// planned deletions per backup config and reason
+	plannedDeletions *prometheus.GaugeVec
+	// planned deletions within their grace period per backup config
+	pendingDeletions *prometheus.GaugeVec
+	// deletions withheld by a safeguard per backup config and safeguard
+	withheldDeletions *prometheus.GaugeVec
+	// the latest deletion plan of every backup config, served over HTTP
+	plans *deletionplans
+	// the amount of active backup configs and the generation of the active config set
+	activeConfigs    prometheus.Gauge
+	configGeneration prometheus.Gauge
+}
+
+// configerror is an error reading a backup config, the source identifies the object the config is defined in
+type configerror struct {
+	source string
+	err    error
+	// the object the config is defined in, Kubernetes Events about the error are recorded on it
+	object runtime.Object
+}
+
+type evaluationresult struct {
+	retained int
+	deleted  int
 }
 
 type backupcounts struct {
@@ -61,11 +210,18 @@ type backupcounts struct {
 	failed   int
 	skipped  int
 	deleting int
+	held     int
+	// backups whose deletion failed and is no longer retried
+	deletionFailed int
+	// whether the backup after the newest complete backup is overdue
+	rpo rpostatus
 }
 
 func main() {
-	// creates the in-cluster config
-	config, err := rest.InClusterConfig()
+	flag.Parse()
+
+	// creates the client config from the kubeconfig, or the in-cluster config when running in a cluster
+	config, err := loadKubeConfig(*kubeconfigPath, *kubeContext)
 	if err != nil {
 		panic(err.Error())
 	}
@@ -91,160 +247,366 @@ func main() {
 
 	taweretMetrics := initialiseMetrics()
 
-	scheduleEvaluations(dynamicClient, gvr, clientSet, taweretMetrics)
+	// run a command line subcommand, e.g. taweret list
+	if flag.NArg() > 0 {
+		if err := runCommand(flag.Args(), os.Stdout, dynamicClient, gvr, clientSet, taweretMetrics); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// record Kubernetes Events, e.g. about invalid backup configs
+	initialiseEventRecorder(clientSet)
+
+	// evaluate once, e.g. for ad-hoc evaluations and dry runs from outside of the cluster
+	if *once {
+		startEvaluation(dynamicClient, gvr, clientSet, taweretMetrics)
+		return
+	}
+
+	// every replica serves metrics and health endpoints, only the leader evaluates backup configs
+	if *leaderElect {
+		go runLeaderElection(clientSet, *leaderElectionNamespace, *leaderElectionID, func(ctx context.Context) {
+			scheduleEvaluations(ctx, dynamicClient, gvr, clientSet, taweretMetrics)
+		})
+	} else {
+		scheduleEvaluations(context.Background(), dynamicClient, gvr, clientSet, taweretMetrics)
+	}
 
 	http.Handle("/metrics", promhttp.Handler())
-	http.ListenAndServe(":2112", nil)
+	http.Handle("/plan", taweretMetrics.plans)
+	http.HandleFunc("/healthz", healthHandler)
+	http.ListenAndServe(*listenAddress, nil)
 }
 
-func scheduleEvaluations(dynamicClient dynamic.Interface, gvr schema.GroupVersionResource, clientSet *kubernetes.Clientset, taweretMetrics taweretmetrics) {
-	// set evaluation schedule
-	const evalSchedule string = "1/1 * * * *"
-
-	// schedule backup evaluations
-	s := gocron.NewScheduler(time.UTC)
-	job, err := s.Cron(evalSchedule).Do(startEvaluation, dynamicClient, gvr, clientSet, taweretMetrics)
-	if err != nil {
-		log.Fatalf("error creating job: %v", err)
+// load the client config with the standard kubeconfig loading rules, which fall back to the in-cluster config when no kubeconfig is found
+func loadKubeConfig(kubeconfigPath string, kubeContext string) (*rest.Config, error) {
+	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+	if kubeconfigPath != "" {
+		loadingRules.ExplicitPath = kubeconfigPath
 	}
-	s.StartAsync()
-	log.Printf("first evaluation scheduled: %v, evaluation schedule: %v", job.NextRun(), evalSchedule)
+	overrides := &clientcmd.ConfigOverrides{CurrentContext: kubeContext}
+
+	return clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, overrides).ClientConfig()
+}
 
+// schedules the evaluation of every backup config on its evaluation schedule until the context is cancelled, evaluations are rescheduled
+// whenever a backup config changes
+func scheduleEvaluations(ctx context.Context, dynamicClient dynamic.Interface, gvr schema.GroupVersionResource, clientSet kubernetes.Interface, taweretMetrics taweretmetrics) {
+	evaluationScheduler := newEvaluationScheduler(func(backupConfig backupconfig) {
+		evaluateBackupConfig(dynamicClient, gvr, taweretMetrics, backupConfig)
+	})
+	// the last evaluation of a removed backup config may have recreated its series
+	evaluationScheduler.unscheduled = taweretMetrics.deleteConfigSeries
+	configSet := newConfigSet(taweretMetrics, func(backupConfigs []backupconfig) {
+		for _, err := range evaluationScheduler.sync(backupConfigs) {
+			log.Printf("skipping backup config: %v\n", err)
+			taweretMetrics.recordConfigError(err)
+		}
+	})
+	watchBackupConfigs(ctx, dynamicClient, clientSet, configSet)
+
+	// stop scheduling evaluations once the context is cancelled, e.g. when the leader lease is lost
+	go func() {
+		<-ctx.Done()
+		log.Printf("stopping backup config evaluations\n")
+		evaluationScheduler.stop()
+	}()
 }
 
-func startEvaluation(dynamicClient dynamic.Interface, gvr schema.GroupVersionResource, clientSet *kubernetes.Clientset, taweretMetrics taweretmetrics) {
+func startEvaluation(dynamicClient dynamic.Interface, gvr schema.GroupVersionResource, clientSet kubernetes.Interface, taweretMetrics taweretmetrics) {
 	log.Printf("starting backup config evaluations\n")
 
-	// get backupConfigs
-	backupConfigs := getBackupConfigs(clientSet, gvr)
+	// get backupConfigs, configs which cannot be read are skipped
+	backupConfigs, configErrors := loadBackupConfigs(dynamicClient, gvr, clientSet)
+	for _, err := range configErrors {
+		log.Printf("skipping backup config: %v\n", err)
+		taweretMetrics.recordConfigError(err)
+	}
 
-	// evaluate backupConfigs
+	// evaluate backupConfigs in parallel up to the configured concurrency, a failing config does not stop the evaluation of the other configs
+	var wg sync.WaitGroup
 	for _, backupConfig := range backupConfigs {
-		evaluateBackups(dynamicClient, gvr, taweretMetrics, backupConfig)
+		wg.Add(1)
+		go func(backupConfig backupconfig) {
+			defer wg.Done()
+			evaluateBackupConfig(dynamicClient, gvr, taweretMetrics, backupConfig)
+		}(backupConfig)
 	}
+	wg.Wait()
 	log.Printf("backup config evaluations complete\n---\n")
 }
 
-func evaluateBackups(dynamicClient dynamic.Interface, gvr schema.GroupVersionResource, taweretMetrics taweretmetrics, backupConfig backupconfig) {
+// evaluates a single backup config and records its result, skipping the config if its previous evaluation is still running
+func evaluateBackupConfig(dynamicClient dynamic.Interface, gvr schema.GroupVersionResource, taweretMetrics taweretmetrics, backupConfig backupconfig) {
+	unlock, ok := configLocks.tryLock(backupConfig.Name)
+	if !ok {
+		log.Printf("%v: previous evaluation is still running, skipping\n", backupConfig.Name)
+		return
+	}
+	defer unlock()
+	defer evaluationSlots.acquire()()
+
+	result, err := evaluateBackups(dynamicClient, gvr, taweretMetrics, backupConfig)
+	if err != nil {
+		log.Printf("%v: backup evaluation failed: %v\n", backupConfig.Name, err)
+		taweretMetrics.evaluationErrors.WithLabelValues(backupConfig.Name).Inc()
+	}
+	updatePolicyStatus(dynamicClient, backupConfig, result, err)
+}
+
+// get the backup configs from ConfigMaps and BackupPolicies, applying the global dry run mode
+func loadBackupConfigs(dynamicClient dynamic.Interface, gvr schema.GroupVersionResource, clientSet kubernetes.Interface) ([]backupconfig, []error) {
+	backupConfigs, configErrors := getBackupConfigs(clientSet, gvr)
+	policyConfigs, policyErrors := getBackupPolicies(dynamicClient)
+	backupConfigs = append(backupConfigs, policyConfigs...)
+
+	for i := range backupConfigs {
+		backupConfigs[i] = withGlobalDryRun(backupConfigs[i])
+	}
+	backupConfigs, validationErrors := validateBackupConfigs(backupConfigs)
+	configErrors = append(configErrors, policyErrors...)
+	return backupConfigs, append(configErrors, validationErrors...)
+}
+
+// applies the global dry run mode to a backup config
+func withGlobalDryRun(backupConfig backupconfig) backupconfig {
+	if *globalDryRun {
+		backupConfig.DryRun = true
+	}
+	return backupConfig
+}
+
+func evaluateBackups(dynamicClient dynamic.Interface, gvr schema.GroupVersionResource, taweretMetrics taweretmetrics, backupConfig backupconfig) (evaluationresult, error) {
+	var result evaluationresult
 
 	log.Printf("%v: evaluating backups\n", backupConfig.Name)
 
-	backups := getBackups(dynamicClient, gvr, backupConfig)
+	backups, skipErrors, err := getBackups(dynamicClient, gvr, backupConfig)
+	if err != nil {
+		return result, err
+	}
+
+	// finish deletions which completed while Taweret was not watching, e.g. before a restart
+	if !backupConfig.DryRun {
+		backups, err = resumeDeletions(backups, dynamicClient, gvr, backupConfig)
+		if err != nil {
+			return result, err
+		}
+	}
+
+	categorisedBackups, expiredBackups, backupCounts := categoriseBackups(backups, backupConfig)
 
-	categorisedBackups, backupCounts := categoriseBackups(backups, backupConfig)
+	// determine which backups should be deleted and why
+	plan, withheld := planDeletions(categorisedBackups, expiredBackups, backupConfig)
+	taweretMetrics.setPlan(plan, backupConfig)
+	taweretMetrics.setWithheldDeletions(withheld, backupConfig)
 
-	// if there are excess daily backups, delete the oldest excess, then refetch and recategorise the backups
-	if len(categorisedBackups) > int(backupConfig.Retention.Backups) {
-		deleteOldestBackups(categorisedBackups, (len(categorisedBackups) - int(backupConfig.Retention.Backups)), dynamicClient, gvr, backupConfig)
-		backups = getBackups(dynamicClient, gvr, backupConfig)
-		categorisedBackups, backupCounts = categoriseBackups(backups, backupConfig)
+	// with a deletion grace period, planned backups are marked first and only deleted once the grace period has passed
+	now := time.Now()
+	softDeletion := applyGracePeriod(plan, backups, backupConfig, now)
+	taweretMetrics.pendingDeletions.WithLabelValues(backupConfig.Name).Set(float64(softDeletion.pending))
+
+	// failed and skipped backups beyond their retention are cleaned up, and stale pending backups reported
+	cleanup := planCleanup(backups, backupConfig, now)
+	for _, stale := range cleanup.stalePending {
+		log.Printf("%v: backup actionset %v has been pending since %v\n", backupConfig.Name, stale.name, stale.time.UTC())
+	}
+	taweretMetrics.stalePendingActionSets.WithLabelValues(backupConfig.Name).Set(float64(len(cleanup.stalePending)))
+
+	// in dry run mode only report the planned deletions, otherwise delete the planned backups, then refetch and recategorise the backups
+	if backupConfig.DryRun {
+		for _, deletion := range plan {
+			log.Printf("%v: dry run: would delete backup %v, backup time: %v, reason: %v\n", backupConfig.Name, deletion.backup.id(), deletion.backup.time.UTC(), deletion.reason)
+		}
+		for _, aBackup := range cleanup.backups {
+			log.Printf("%v: dry run: would clean up %v backup %v, backup time: %v\n", backupConfig.Name, aBackup.status, aBackup.id(), aBackup.time.UTC())
+		}
+		log.Printf("%v: dry run: %v backups would be deleted\n", backupConfig.Name, len(plan))
 	} else {
-		log.Printf("%v: no backups deleted: current: %v limit: %v\n", backupConfig.Name, len(categorisedBackups), backupConfig.Retention.Backups)
+		if err := applySoftDeletion(softDeletion, dynamicClient, gvr, backupConfig, now); err != nil {
+			return result, err
+		}
+		cleaned, err := cleanupBackups(cleanup, dynamicClient, gvr, taweretMetrics, backupConfig, now)
+		if err != nil {
+			return result, err
+		}
+		if len(softDeletion.due) > 0 {
+			result.deleted, err = deletePlannedBackups(softDeletion.due, dynamicClient, gvr, taweretMetrics, backupConfig)
+			if err != nil {
+				return result, err
+			}
+		}
+		if len(softDeletion.due) > 0 || cleaned > 0 {
+			backups, skipErrors, err = getBackups(dynamicClient, gvr, backupConfig)
+			if err != nil {
+				return result, err
+			}
+			categorisedBackups, _, backupCounts = categoriseBackups(backups, backupConfig)
+		}
+
+		// remove the deletion actionsets which are no longer needed
+		if err := collectDeletionActionSets(backups, dynamicClient, gvr, taweretMetrics, backupConfig, now); err != nil {
+			return result, err
+		}
 	}
 
 	taweretMetrics.setMetrics(categorisedBackups, backupConfig, backupCounts)
+	taweretMetrics.setSkippedActionSets(skipErrors, backupConfig)
+	result.retained = len(categorisedBackups)
 
 	log.Printf("%v: backup evaluation complete\n", backupConfig.Name)
+	return result, nil
 }
 
-func getBackupConfigs(clientset *kubernetes.Clientset, gvr schema.GroupVersionResource) []backupconfig {
+// queries Kubernetes for ConfigMaps with a backup-config.yaml key and returns their backup configs. ConfigMaps which cannot be read are
+// returned as errors, so that the other backup configs can still be evaluated.
+func getBackupConfigs(clientset kubernetes.Interface, gvr schema.GroupVersionResource) ([]backupconfig, []error) {
 	var backupConfigs []backupconfig
-	// get configmaps
-	configmaps, err := clientset.CoreV1().ConfigMaps("kanister").List(context.TODO(), v1.ListOptions{})
-	if err != nil {
-		log.Printf("error getting actionsets: %v\n", err)
-		os.Exit(1)
+	var configErrors []error
+	// get configmaps of all watched namespaces
+	var configmaps []corev1.ConfigMap
+	for _, namespace := range watchedConfigNamespaces() {
+		configmapList, err := clientset.CoreV1().ConfigMaps(namespace).List(context.TODO(), v1.ListOptions{LabelSelector: *configSelector})
+		if err != nil {
+			configErrors = append(configErrors, &configerror{source: "configmaps", err: fmt.Errorf("error getting configmaps: %w", err)})
+			continue
+		}
+		configmaps = append(configmaps, configmapList.Items...)
 	}
 
-	for _, configmap := range configmaps.Items {
-		if configmap.Data["backup-config.yaml"] != "" {
-			var backupConfig backupconfig
+	for i := range configmaps {
+		backupConfig, ok, err := backupConfigFromConfigMap(&configmaps[i])
+		if err != nil {
+			configErrors = append(configErrors, err)
+			continue
+		}
+		if !ok {
+			continue
+		}
 
-			err = yaml.Unmarshal([]byte(configmap.Data["backup-config.yaml"]), &backupConfig)
-			if err != nil {
-				log.Printf("error unmarshalling backup-config.yaml: %v\n", err)
-				os.Exit(1)
-			}
+		backupConfigs = append(backupConfigs, backupConfig)
 
-			backupConfigs = append(backupConfigs, backupConfig)
+		log.Printf("backup config:\n name: %v\n kanister namespace: %v\n blueprint name: %v\n profile name: %v\n retention:\n backups: %v\n years: %v months: %v days: %v hours %v minutes: %v", backupConfig.Name, backupConfig.KanisterNamespace, backupConfig.BlueprintName, backupConfig.ProfileName, backupConfig.Retention.Backups, backupConfig.Retention.Years, backupConfig.Retention.Months, backupConfig.Retention.Days, backupConfig.Retention.Hours, backupConfig.Retention.Minutes)
+	}
+	return backupConfigs, configErrors
+}
 
-			log.Printf("backup config:\n name: %v\n kanister namespace: %v\n blueprint name: %v\n profile name: %v\n retention:\n backups: %v\n years: %v months: %v days: %v hours %v minutes: %v", backupConfig.Name, backupConfig.KanisterNamespace, backupConfig.BlueprintName, backupConfig.ProfileName, backupConfig.Retention.Backups, backupConfig.Retention.Years, backupConfig.Retention.Months, backupConfig.Retention.Days, backupConfig.Retention.Hours, backupConfig.Retention.Minutes)
+// the namespaces in which backup configs are discovered, all namespaces are returned as the empty namespace
+func watchedConfigNamespaces() []string {
+	var namespaces []string
+	for _, namespace := range strings.Split(*configNamespaces, ",") {
+		namespace = strings.TrimSpace(namespace)
+		if namespace == allNamespaces {
+			return []string{v1.NamespaceAll}
 		}
+		if namespace != "" {
+			namespaces = append(namespaces, namespace)
+		}
+	}
+	if len(namespaces) == 0 {
+		return []string{configNamespace}
 	}
-	return backupConfigs
+	return namespaces
 }
 
-// queries Kubernetes for Actionsets, adds the actionsets with action name 'backup' to a slice of backup objects and returns the slice
-func getBackups(dynamicClient dynamic.Interface, gvr schema.GroupVersionResource, backupConfig backupconfig) []backup {
+// reads the backup config of a ConfigMap, returning false if the ConfigMap has no backup-config.yaml
+func backupConfigFromConfigMap(configmap *corev1.ConfigMap) (backupconfig, bool, error) {
+	var backupConfig backupconfig
+	if configmap.Data["backup-config.yaml"] == "" {
+		return backupConfig, false, nil
+	}
+
+	source := "configmap/" + configmap.Namespace + "/" + configmap.Name
+	// unknown and duplicate keys are errors, e.g. a misspelled retention
+	err := yaml.UnmarshalStrict([]byte(configmap.Data["backup-config.yaml"]), &backupConfig)
+	if err != nil {
+		return backupConfig, false, &configerror{source: source, err: fmt.Errorf("error unmarshalling backup-config.yaml: %w", err), object: configmap}
+	}
+	backupConfig.source = source
+	backupConfig.object = configmap
+	return backupConfig, true, nil
+}
+
+// queries Kubernetes for Actionsets, adds every action with action name 'backup' to a slice of backup objects and returns the slice.
+// ActionSets which cannot be parsed are skipped and returned as errors.
+func getBackups(dynamicClient dynamic.Interface, gvr schema.GroupVersionResource, backupConfig backupconfig) ([]backup, []error, error) {
 	var backups []backup
+	var skipErrors []error
 
 	log.Printf("%v: retrieving actionsets from Kubernetes", backupConfig.Name)
 
 	// get actionsets
 	actionsets, err := dynamicClient.Resource(gvr).Namespace(backupConfig.KanisterNamespace).List(context.Background(), v1.ListOptions{})
 	if err != nil {
-		log.Printf("%v: error getting actionsets: %v\n", backupConfig.Name, err)
-		os.Exit(1)
+		return nil, nil, fmt.Errorf("error getting actionsets: %w", err)
 	}
 
 	log.Printf("%v: filtering backup actionsets from Kubernetes", backupConfig.Name)
 
+	// the deletion actionsets link backups to their deletion, also across restarts
+	deletions := latestDeletions(actionsets.Items)
+	now := time.Now()
+
 	// loop through actionsets
 	for _, actionset := range actionsets.Items {
-		actionSpec := actionset.Object["spec"].(map[string]interface{})["actions"].([]interface{})[0].(map[string]interface{})
-		actionMetadata := actionset.Object["metadata"].(map[string]interface{})
-
-		// skip ahead if the ActionSet is not a backup
-		if actionSpec["name"] != "backup" {
-			continue
-		}
-
-		// check for the existence of the keys, if they do not exist, return early. The if statements are split up to avoid runtime errors.
-		if _, ok := actionSpec["options"]; !ok {
-			continue
+		actionsetBackups, actionsetErrors := parseBackupActionSet(actionset, backupConfig)
+		for _, err := range actionsetErrors {
+			log.Printf("%v: skipping %v\n", backupConfig.Name, err)
 		}
-		if _, ok := actionSpec["options"].(map[string]interface{})["backup-schedule"]; !ok {
-			continue
-		}
-		if _, ok := actionset.Object["status"].(map[string]interface{})["actions"].([]interface{})[0].(map[string]interface{})["artifacts"].(map[string]interface{})["cloudObject"].(map[string]interface{})["keyValue"].(map[string]interface{})["backupLocation"]; !ok {
-			continue
-		}
-
-		thisBackup := backup{
-			name:           fmt.Sprintf("%v", actionMetadata["name"]),
-			status:         fmt.Sprintf("%v", actionset.Object["status"].(map[string]interface{})["state"]),
-			schedule:       fmt.Sprintf("%v", actionSpec["options"].(map[string]interface{})["backup-schedule"]),
-			backupLocation: fmt.Sprintf("%v", actionset.Object["status"].(map[string]interface{})["actions"].([]interface{})[0].(map[string]interface{})["artifacts"].(map[string]interface{})["cloudObject"].(map[string]interface{})["keyValue"].(map[string]interface{})["backupLocation"]),
-		}
-		thisBackup.time, _ = time.Parse(time.RFC3339, fmt.Sprintf("%v", actionMetadata["creationTimestamp"]))
-		if thisBackup.schedule == backupConfig.Name {
-			backups = append(backups, thisBackup)
+		skipErrors = append(skipErrors, actionsetErrors...)
+		for _, aBackup := range actionsetBackups {
+			aBackup.deletion = deletions[aBackup.id()].withTimeout(backupConfig, now)
+			// a backup whose deletion is in progress or complete is no longer retained, nor is a backup which could not be deleted
+			if aBackup.deletion.inProgress() || aBackup.deletion.state == string(v1alpha1.StateComplete) {
+				aBackup.status = "deleting"
+			} else if aBackup.deletion.retriesExhausted(backupConfig) {
+				aBackup.status = statusDeletionFailed
+			}
+			backups = append(backups, aBackup)
 		}
 	}
-	return backups
+	return backups, skipErrors, nil
 }
 
-// determine whether individual backups are required based on max retention dates and their category (daily, weekly, none)
-func categoriseBackups(uncategorisedBackups []backup, backupConfig backupconfig) ([]backup, backupcounts) {
+// determine whether individual backups are required based on max retention dates, returning the backups in use, the expired complete backups and the counts per state
+func categoriseBackups(uncategorisedBackups []backup, backupConfig backupconfig) ([]backup, []backup, backupcounts) {
 	var categorisedBackups []backup
+	var expiredBackups []backup
 	backupCounts := backupcounts{
 		pending:  0,
 		running:  0,
 		failed:   0,
 		skipped:  0,
 		deleting: 0,
+		held:     0,
+
+		deletionFailed: 0,
 	}
 
 	log.Printf("%v: categorising backups\n", backupConfig.Name)
 
-	maxBackupDateTime := time.Now()
+	now := time.Now()
+	maxBackupDateTime, ageRetention := retentionCutoff(backupConfig, now)
 
-	maxBackupDateTime = maxBackupDateTime.Add(time.Minute * time.Duration(backupConfig.Retention.Minutes) * -1)
-	maxBackupDateTime = maxBackupDateTime.Add(time.Hour * time.Duration(backupConfig.Retention.Hours) * -1)
-	maxBackupDateTime = maxBackupDateTime.AddDate(int(backupConfig.Retention.Years)*-1, int(backupConfig.Retention.Months)*-1, int(backupConfig.Retention.Days)*-1)
+	// the newest complete backup, whether it is held, expired or retained
+	var newestComplete time.Time
 
 	for _, aBackup := range uncategorisedBackups {
-		if aBackup.time.After(maxBackupDateTime) && aBackup.status == "complete" {
+		if aBackup.status == "complete" {
+			if aBackup.time.After(newestComplete) {
+				newestComplete = aBackup.time
+			}
+			// held backups are neither counted nor expired
+			if aBackup.isHeld(now) {
+				backupCounts.held++
+				continue
+			}
+			if ageRetention && !aBackup.time.After(maxBackupDateTime) {
+				expiredBackups = append(expiredBackups, aBackup)
+				continue
+			}
 			aBackup.inUse = true
 			categorisedBackups = append(categorisedBackups, aBackup)
 		} else if aBackup.status == "pending" {
@@ -257,21 +619,55 @@ func categoriseBackups(uncategorisedBackups []backup, backupConfig backupconfig)
 			backupCounts.skipped++
 		} else if aBackup.status == "deleting" {
 			backupCounts.deleting++
+		} else if aBackup.status == statusDeletionFailed {
+			backupCounts.deletionFailed++
 		}
 	}
 
+	backupCounts.rpo = rpoStatus(newestComplete, backupConfig, now)
+
 	categorisedAndSortedBackups := sortBackups(categorisedBackups, backupConfig)
+	expiredAndSortedBackups := sortBackups(expiredBackups, backupConfig)
+
+	return categorisedAndSortedBackups, expiredAndSortedBackups, backupCounts
+}
 
-	return categorisedAndSortedBackups, backupCounts
+// calculate the creation time before which complete backups are expired. The second return value is false when no age based retention is configured, in which case backups never expire.
+func retentionCutoff(backupConfig backupconfig, now time.Time) (time.Time, bool) {
+	retention := backupConfig.Retention
+	if retention.Minutes <= 0 && retention.Hours <= 0 && retention.Days <= 0 && retention.Months <= 0 && retention.Years <= 0 {
+		return time.Time{}, false
+	}
+
+	maxBackupDateTime := now
+	maxBackupDateTime = maxBackupDateTime.Add(time.Minute * time.Duration(retention.Minutes) * -1)
+	maxBackupDateTime = maxBackupDateTime.Add(time.Hour * time.Duration(retention.Hours) * -1)
+	maxBackupDateTime = maxBackupDateTime.AddDate(int(retention.Years)*-1, int(retention.Months)*-1, int(retention.Days)*-1)
+
+	return maxBackupDateTime, true
 }
 
-// delete a specified number of the oldest backups in a backup slice
-func deleteOldestBackups(backups []backup, count int, dynamicClient dynamic.Interface, gvr schema.GroupVersionResource, backupConfig backupconfig) {
-	backups = sortBackups(backups, backupConfig)
-	for i := 0; i < count; i++ {
-		log.Printf("%v: deleting backup %v, backup time: %v, deletion nr %v, total to delete %v, total backups in category: %v\n", backupConfig.Name, backups[i].name, backups[i].time.UTC(), i+1, count, len(backups))
-		deleteBackup(backups[i], dynamicClient, gvr, backupConfig)
+// delete every backup of a deletion plan, stopping at the first backup which cannot be deleted. Backups whose failed deletion is not due for
+// a retry yet are skipped. Returns the number of deleted backups.
+func deletePlannedBackups(plan []planneddeletion, dynamicClient dynamic.Interface, gvr schema.GroupVersionResource, taweretMetrics taweretmetrics, backupConfig backupconfig) (int, error) {
+	deleted := 0
+	now := time.Now()
+	for i, deletion := range plan {
+		if retryAt := deletion.backup.deletion.retryAt(backupConfig); now.Before(retryAt) {
+			log.Printf("%v: deletion of backup %v failed, retrying after %v\n", backupConfig.Name, deletion.backup.id(), retryAt.UTC())
+			continue
+		}
+		log.Printf("%v: deleting backup %v, backup time: %v, reason: %v, deletion nr %v, total to delete %v\n", backupConfig.Name, deletion.backup.id(), deletion.backup.time.UTC(), deletion.reason, i+1, len(plan))
+		outcome, err := deleteBackup(deletion.backup, dynamicClient, gvr, backupConfig)
+		taweretMetrics.recordDeletion(outcome, backupConfig)
+		if err != nil {
+			return deleted, fmt.Errorf("error deleting backup %v: %w", deletion.backup.id(), err)
+		}
+		if outcome == deletionOutcomeComplete {
+			deleted++
+		}
 	}
+	return deleted, nil
 }
 
 // sort the backup slices with the oldest backups placed at the start of the slice
@@ -284,108 +680,66 @@ func sortBackups(backups []backup, backupConfig backupconfig) []backup {
 }
 
 // deletes a specified backup by creating an actionset with the action 'delete'
-func deleteBackup(unusedBackup backup, dynamicClient dynamic.Interface, gvr schema.GroupVersionResource, backupConfig backupconfig) {
-
-	// set name of deletion actionset
-	deletionActionsetName := fmt.Sprintf("delete-%v", unusedBackup.name)
-
-	// construct actionset crd manifest to delete backup
-	deletionActionSet := v1alpha1.ActionSet{
-		Spec: &v1alpha1.ActionSetSpec{
-			Actions: []v1alpha1.ActionSpec{
-				{
-					Name:      "delete",
-					Blueprint: backupConfig.BlueprintName,
-					Artifacts: map[string]v1alpha1.Artifact{
-						"cloudObject": {
-							KeyValue: map[string]string{
-								"backupLocation": unusedBackup.backupLocation,
-							},
-						},
-					},
-					Object: v1alpha1.ObjectReference{
-						Kind:      "namespace",
-						Name:      backupConfig.KanisterNamespace,
-						Namespace: backupConfig.KanisterNamespace,
-					},
-					Profile: &v1alpha1.ObjectReference{
-						Name:      backupConfig.ProfileName,
-						Namespace: backupConfig.KanisterNamespace,
-					},
-				},
-			},
-		},
-		TypeMeta: v1.TypeMeta{
-			APIVersion: "cr.kanister.io/v1alpha1",
-			Kind:       "ActionSet",
-		},
-		ObjectMeta: v1.ObjectMeta{
-			Name:      deletionActionsetName,
-			Namespace: backupConfig.KanisterNamespace,
-		},
-	}
-
-	// convert to unstructured to apply with dynamicClient
-	myCRAsUnstructured, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&deletionActionSet)
-	if err != nil {
-		panic(err.Error())
-	}
-	myCRUnstructured := &unstructured.Unstructured{Object: myCRAsUnstructured}
+func deleteBackup(unusedBackup backup, dynamicClient dynamic.Interface, gvr schema.GroupVersionResource, backupConfig backupconfig) (string, error) {
+	// wait for a free deletion slot, so that only a limited number of deletion actionsets run at the same time
+	releaseDeletionSlot := deletionSlots.acquire()
+	defer releaseDeletionSlot()
 
-	// apply deletion actionset
-	appliedActionSet, err := dynamicClient.Resource(gvr).Namespace(backupConfig.KanisterNamespace).Create(context.Background(), myCRUnstructured, v1.CreateOptions{})
-	log.Printf("Applying the following deletion actionset: %v", appliedActionSet)
+	// create the deletion actionset, or adopt the existing one of the backup
+	deletion, err := ensureDeletionActionSet(unusedBackup, dynamicClient, gvr, backupConfig)
 	if err != nil {
-		panic(err.Error())
+		return deletionOutcomeError, err
 	}
 
-	// loop to check status of deletion actionset whilst actionset is running
-	for {
-		log.Printf("%v: waiting for %v to complete... ", backupConfig.Name, deletionActionsetName)
-		time.Sleep(5 * time.Second)
-
-		// get deletion actionset
-		actionset, err := dynamicClient.Resource(gvr).Namespace(backupConfig.KanisterNamespace).Get(context.Background(), deletionActionsetName, v1.GetOptions{})
-		if err != nil {
-			log.Printf("%v: error retrieving deletion actionset: %v\n", backupConfig.Name, err)
-			os.Exit(1)
+	// wait for the deletion actionset to complete or fail, giving up after the deletion timeout
+	state, message, err := waitForActionSet(dynamicClient, gvr, backupConfig.KanisterNamespace, deletion.name, backupConfig.deletionTimeout())
+	// a deletion which timed out is retried like a failed deletion, the remaining backups are deleted nevertheless
+	if errors.Is(err, errActionSetTimedOut) {
+		log.Printf("%v: %v did not complete within %v, last state: %v\n", backupConfig.Name, deletion.name, backupConfig.deletionTimeout(), valueOrDefault(state, "unknown"))
+		if err := markDeletionTimedOut(deletion, dynamicClient, gvr, backupConfig, time.Now()); err != nil {
+			return deletionOutcomeError, fmt.Errorf("error marking deletion actionset %v as timed out: %w", deletion.name, err)
 		}
-
-		// check if deletion actionset status is "complete"
-		if actionset.Object["status"].(map[string]interface{})["state"] == "complete" {
-			log.Printf("%v: %v has completed\n", backupConfig.Name, deletionActionsetName)
-			break
+		deletion.state = string(v1alpha1.StateFailed)
+		if deletion.retriesExhausted(backupConfig) {
+			recordDeletionFailed(unusedBackup, deletion, fmt.Sprintf("timed out after %v", backupConfig.deletionTimeout()), dynamicClient, gvr, backupConfig)
 		}
+		return deletionOutcomeTimedOut, nil
+	}
+	if err != nil {
+		return deletionOutcomeError, fmt.Errorf("error waiting for deletion actionset %v: %w", deletion.name, err)
+	}
 
-		// check if deletion actionset status is "failed"
-		if actionset.Object["status"].(map[string]interface{})["state"] == "failed" {
-			log.Printf("%v: error deleting backup with actionset %v, error: %v\n", backupConfig.Name, deletionActionsetName, actionset.Object["status"].(map[string]interface{})["error"].(map[string]interface{})["message"])
-			break
+	// keep the backup actionset of a failed deletion, so that the deletion is retried and the backup data is not orphaned
+	if state != string(v1alpha1.StateComplete) {
+		deletion.state = state
+		log.Printf("%v: error deleting backup with actionset %v, error: %v\n", backupConfig.Name, deletion.name, message)
+		if deletion.retriesExhausted(backupConfig) {
+			recordDeletionFailed(unusedBackup, deletion, message, dynamicClient, gvr, backupConfig)
 		}
-
-		// print current state of deletion actionset
-		log.Printf("%v\n", actionset.Object["status"].(map[string]interface{})["state"])
+		return deletionOutcomeFailed, nil
 	}
+	log.Printf("%v: %v has completed\n", backupConfig.Name, deletion.name)
 
-	// delete backup actionset
-	err = dynamicClient.Resource(gvr).Namespace(backupConfig.KanisterNamespace).Delete(context.Background(), unusedBackup.name, v1.DeleteOptions{})
-	if err != nil {
-		log.Printf("%v: error deleting backup actionset: %v\n", backupConfig.Name, err)
-		os.Exit(1)
+	// remove the backup action from its actionset
+	if err := pruneBackupAction(unusedBackup, dynamicClient, gvr, backupConfig); err != nil {
+		return deletionOutcomeError, err
 	}
-
+	return deletionOutcomeComplete, nil
 }
 
 // UnmarshalYAML is a custom YAML unmarshaller to allow string to stringint type conversion
-func (st *StringInt) UnmarshalYAML(b []byte) error {
+func (st *StringInt) UnmarshalYAML(unmarshal func(interface{}) error) error {
 	var item interface{}
-	if err := yaml.Unmarshal(b, &item); err != nil {
+	if err := unmarshal(&item); err != nil {
 		return err
 	}
 	switch v := item.(type) {
 	case int:
 		*st = StringInt(v)
 	case float64:
+		if v != math.Trunc(v) {
+			return fmt.Errorf("expected an integer, got %v", v)
+		}
 		*st = StringInt(int(v))
 	case string:
 		i, err := strconv.Atoi(v)
@@ -393,12 +747,41 @@ func (st *StringInt) UnmarshalYAML(b []byte) error {
 			return err
 		}
 		*st = StringInt(i)
+	case nil:
+		*st = 0
+	default:
+		return fmt.Errorf("expected an integer, got %v", v)
 	}
 	return nil
 }
 
 // initialise Prometheus metrics
 func initialiseMetrics() taweretmetrics {
+	taweretMetrics := newTaweretMetrics()
+
+	prometheus.MustRegister(taweretMetrics.backupCount)
+	prometheus.MustRegister(taweretMetrics.oldestBackup)
+	prometheus.MustRegister(taweretMetrics.newestBackup)
+	prometheus.MustRegister(taweretMetrics.rpoViolation)
+	prometheus.MustRegister(taweretMetrics.overdueSeconds)
+	prometheus.MustRegister(taweretMetrics.plannedDeletions)
+	prometheus.MustRegister(taweretMetrics.withheldDeletions)
+	prometheus.MustRegister(taweretMetrics.pendingDeletions)
+	prometheus.MustRegister(taweretMetrics.deletions)
+	prometheus.MustRegister(taweretMetrics.collectedActionSets)
+	prometheus.MustRegister(taweretMetrics.cleanedActionSets)
+	prometheus.MustRegister(taweretMetrics.stalePendingActionSets)
+	prometheus.MustRegister(taweretMetrics.evaluationErrors)
+	prometheus.MustRegister(taweretMetrics.configErrors)
+	prometheus.MustRegister(taweretMetrics.skippedActionSets)
+	prometheus.MustRegister(taweretMetrics.activeConfigs)
+	prometheus.MustRegister(taweretMetrics.configGeneration)
+
+	return taweretMetrics
+}
+
+// create the Prometheus metrics without registering them
+func newTaweretMetrics() taweretmetrics {
 	var taweretMetrics taweretmetrics
 	taweretMetrics.backupCount = prometheus.NewGaugeVec(
 		prometheus.GaugeOpts{
@@ -433,9 +816,153 @@ func initialiseMetrics() taweretmetrics {
 		},
 	)
 
-	prometheus.MustRegister(taweretMetrics.backupCount)
-	prometheus.MustRegister(taweretMetrics.oldestBackup)
-	prometheus.MustRegister(taweretMetrics.newestBackup)
+	taweretMetrics.plannedDeletions = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "backup_planned_deletions",
+			Help: "The amount of backups which the last evaluation planned to delete, or would delete in dry run mode",
+		},
+		[]string{
+			// which backup config
+			"backup_config_name",
+			// why the backups are deleted
+			"reason",
+			// whether the backup config is evaluated in dry run mode
+			"dry_run",
+		},
+	)
+	taweretMetrics.withheldDeletions = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "backup_withheld_deletions",
+			Help: "The amount of planned deletions which the last evaluation withheld because of a safeguard",
+		},
+		[]string{
+			// which backup config
+			"backup_config_name",
+			// min-keep or stale-backups
+			"guard",
+		},
+	)
+	taweretMetrics.pendingDeletions = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "backup_pending_deletions",
+			Help: "The amount of backups marked for deletion whose grace period has not passed yet",
+		},
+		[]string{
+			// which backup config
+			"backup_config_name",
+		},
+	)
+	taweretMetrics.plans = newDeletionPlans()
+	taweretMetrics.deletions = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "backup_deletions_total",
+			Help: "The amount of backup deletions by outcome",
+		},
+		[]string{
+			// which backup config
+			"backup_config_name",
+			// complete, failed, timed_out or error
+			"outcome",
+		},
+	)
+	taweretMetrics.rpoViolation = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "backup_rpo_violation",
+			Help: "1 if the backup expected after the newest complete backup is overdue, 0 otherwise",
+		},
+		[]string{
+			// which backup config
+			"backup_config_name",
+		},
+	)
+	taweretMetrics.overdueSeconds = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "backup_overdue_seconds",
+			Help: "How long the backup expected after the newest complete backup is overdue, +Inf without any complete backup",
+		},
+		[]string{
+			// which backup config
+			"backup_config_name",
+		},
+	)
+	taweretMetrics.collectedActionSets = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "backup_collected_actionsets_total",
+			Help: "The amount of finished deletion ActionSets which were garbage-collected",
+		},
+		[]string{
+			// which backup config
+			"backup_config_name",
+			// complete or failed
+			"state",
+		},
+	)
+	taweretMetrics.cleanedActionSets = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "backup_cleaned_actionsets_total",
+			Help: "The amount of failed and skipped backup ActionSets which were cleaned up",
+		},
+		[]string{
+			// which backup config
+			"backup_config_name",
+			// failed, attemptfailed or skipped
+			"state",
+		},
+	)
+	taweretMetrics.stalePendingActionSets = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "backup_stale_pending_actionsets",
+			Help: "The amount of backup ActionSets which have been pending for longer than the stale pending threshold",
+		},
+		[]string{
+			// which backup config
+			"backup_config_name",
+		},
+	)
+	taweretMetrics.skippedActionSets = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "backup_skipped_actionsets",
+			Help: "The amount of ActionSets which were skipped because they could not be parsed",
+		},
+		[]string{
+			// which backup config
+			"backup_config_name",
+			// why the ActionSets were skipped
+			"reason",
+		},
+	)
+	taweretMetrics.evaluationErrors = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "backup_evaluation_errors_total",
+			Help: "The amount of failed backup config evaluations",
+		},
+		[]string{
+			// which backup config
+			"backup_config_name",
+		},
+	)
+	taweretMetrics.configErrors = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "backup_config_errors_total",
+			Help: "The amount of times a backup config could not be read",
+		},
+		[]string{
+			// where the backup config is defined, e.g. configmap/<name>
+			"source",
+		},
+	)
+	taweretMetrics.activeConfigs = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "backup_configs",
+			Help: "The amount of active backup configs",
+		},
+	)
+	taweretMetrics.configGeneration = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "backup_config_generation",
+			Help: "The generation of the active backup configs, increased whenever a backup config is added, changed or removed",
+		},
+	)
 
 	return taweretMetrics
 }
@@ -454,11 +981,117 @@ func (taweretMetrics *taweretmetrics) setMetrics(backups []backup, backupConfig
 		taweretMetrics.newestBackup.WithLabelValues(backupConfig.Name).Set(0)
 	}
 
-	// set backupCount for completed, pending, running, failed, skipped and deleting state backups
+	// set backupCount for completed, pending, running, failed, skipped, deleting, held and deletion_failed state backups
 	taweretMetrics.backupCount.WithLabelValues(backupConfig.Name, "completed").Set(float64(len(backups)))
 	taweretMetrics.backupCount.WithLabelValues(backupConfig.Name, "pending").Set(float64(backupCounts.pending))
 	taweretMetrics.backupCount.WithLabelValues(backupConfig.Name, "running").Set(float64(backupCounts.running))
 	taweretMetrics.backupCount.WithLabelValues(backupConfig.Name, "failed").Set(float64(backupCounts.failed))
 	taweretMetrics.backupCount.WithLabelValues(backupConfig.Name, "skipped").Set(float64(backupCounts.skipped))
 	taweretMetrics.backupCount.WithLabelValues(backupConfig.Name, "deleting").Set(float64(backupCounts.deleting))
+	taweretMetrics.backupCount.WithLabelValues(backupConfig.Name, "held").Set(float64(backupCounts.held))
+	taweretMetrics.backupCount.WithLabelValues(backupConfig.Name, statusDeletionFailed).Set(float64(backupCounts.deletionFailed))
+
+	// the RPO metrics are only exported for backup configs which expect backups
+	if backupCounts.rpo.configured {
+		rpoViolation := 0.0
+		if backupCounts.rpo.violation {
+			rpoViolation = 1
+		}
+		taweretMetrics.rpoViolation.WithLabelValues(backupConfig.Name).Set(rpoViolation)
+		taweretMetrics.overdueSeconds.WithLabelValues(backupConfig.Name).Set(backupCounts.rpo.overdueSeconds())
+	} else {
+		taweretMetrics.rpoViolation.DeleteLabelValues(backupConfig.Name)
+		taweretMetrics.overdueSeconds.DeleteLabelValues(backupConfig.Name)
+	}
+}
+
+// set the planned deletions metric and store the deletion plan of a backup config
+func (taweretMetrics *taweretmetrics) setPlan(plan []planneddeletion, backupConfig backupconfig) {
+	plannedCounts := map[string]int{
+		deletionReasonAge:              0,
+		deletionReasonCount:            0,
+		deletionReasonRetentionBuckets: 0,
+	}
+	for _, deletion := range plan {
+		plannedCounts[deletion.reason]++
+	}
+
+	// remove the series of the other dry run mode, so that only the current mode is reported
+	taweretMetrics.plannedDeletions.DeletePartialMatch(prometheus.Labels{"backup_config_name": backupConfig.Name, "dry_run": strconv.FormatBool(!backupConfig.DryRun)})
+	for reason, count := range plannedCounts {
+		taweretMetrics.plannedDeletions.WithLabelValues(backupConfig.Name, reason, strconv.FormatBool(backupConfig.DryRun)).Set(float64(count))
+	}
+
+	taweretMetrics.plans.set(plan, backupConfig)
+}
+
+// increase the config errors metric for the source of a config error, and record a Kubernetes Event on the object the config is defined in
+func (taweretMetrics *taweretmetrics) recordConfigError(err error) {
+	source := "unknown"
+	var configErr *configerror
+	if errors.As(err, &configErr) {
+		source = configErr.source
+		recordWarningEvent(configErr.object, eventReasonInvalidBackupConfig, configErr.err.Error())
+	}
+	taweretMetrics.configErrors.WithLabelValues(source).Inc()
+}
+
+func (configErr *configerror) Error() string {
+	return fmt.Sprintf("%v: %v", configErr.source, configErr.err)
+}
+
+func (configErr *configerror) Unwrap() error {
+	return configErr.err
+}
+
+// set the skipped ActionSets metric of a backup config
+func (taweretMetrics *taweretmetrics) setSkippedActionSets(skipErrors []error, backupConfig backupconfig) {
+	skippedCounts := map[string]int{
+		skipReasonMalformed:       0,
+		skipReasonMissingArtifact: 0,
+	}
+	for _, err := range skipErrors {
+		var actionsetErr *actionseterror
+		if errors.As(err, &actionsetErr) {
+			skippedCounts[actionsetErr.reason]++
+		}
+	}
+	for reason, count := range skippedCounts {
+		taweretMetrics.skippedActionSets.WithLabelValues(backupConfig.Name, reason).Set(float64(count))
+	}
+}
+
+// increase the deletions metric for the outcome of a backup deletion
+func (taweretMetrics *taweretmetrics) recordDeletion(outcome string, backupConfig backupconfig) {
+	if taweretMetrics.deletions == nil {
+		return
+	}
+	taweretMetrics.deletions.WithLabelValues(backupConfig.Name, outcome).Inc()
+}
+
+// remove every series of a backup config, e.g. when the backup config was removed
+func (taweretMetrics *taweretmetrics) deleteConfigSeries(backupConfigName string) {
+	labels := prometheus.Labels{"backup_config_name": backupConfigName}
+	taweretMetrics.backupCount.DeletePartialMatch(labels)
+	taweretMetrics.oldestBackup.DeletePartialMatch(labels)
+	taweretMetrics.newestBackup.DeletePartialMatch(labels)
+	taweretMetrics.rpoViolation.DeletePartialMatch(labels)
+	taweretMetrics.overdueSeconds.DeletePartialMatch(labels)
+	taweretMetrics.plannedDeletions.DeletePartialMatch(labels)
+	taweretMetrics.withheldDeletions.DeletePartialMatch(labels)
+	taweretMetrics.pendingDeletions.DeletePartialMatch(labels)
+	taweretMetrics.deletions.DeletePartialMatch(labels)
+	taweretMetrics.collectedActionSets.DeletePartialMatch(labels)
+	taweretMetrics.cleanedActionSets.DeletePartialMatch(labels)
+	taweretMetrics.stalePendingActionSets.DeletePartialMatch(labels)
+	taweretMetrics.evaluationErrors.DeletePartialMatch(labels)
+	taweretMetrics.skippedActionSets.DeletePartialMatch(labels)
+	taweretMetrics.plans.remove(backupConfigName)
+}
+
+// set the withheld deletions metric of a backup config
+func (taweretMetrics *taweretmetrics) setWithheldDeletions(withheld map[string]int, backupConfig backupconfig) {
+	for guard, count := range withheld {
+		taweretMetrics.withheldDeletions.WithLabelValues(backupConfig.Name, guard).Set(float64(count))
+	}
 }
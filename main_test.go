@@ -1,14 +1,22 @@
 package main
 
 import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
 	"sort"
 	"testing"
 	"time"
 
+	"github.com/kanisterio/kanister/pkg/apis/cr/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	fake "k8s.io/client-go/dynamic/fake"
+	kubernetesfake "k8s.io/client-go/kubernetes/fake"
 )
 
 func newUnstructuredBackup(name, namespace, creationTimestamp, actionName, schedule, status, backupLocation string) *unstructured.Unstructured {
@@ -51,9 +59,12 @@ func newUnstructuredBackup(name, namespace, creationTimestamp, actionName, sched
 
 func TestGetBackups(t *testing.T) {
 	defaultTime, _ := time.Parse(time.RFC3339, "2022-01-01T02:03:04.52Z")
+	defaultArtifacts := map[string]v1alpha1.Artifact{
+		"cloudObject": {KeyValue: map[string]string{"backupLocation": "pg_backups/renku/renku-postgresql/2022-01-01T02:03:04.52Z/backup.sql.gz"}},
+	}
 	expectedBackups := []backup{
-		{name: "backup-foo", schedule: "weekly", status: "complete", time: defaultTime, backupLocation: "pg_backups/renku/renku-postgresql/2022-01-01T02:03:04.52Z/backup.sql.gz"},
-		{name: "backup-bar", schedule: "daily", status: "complete", time: defaultTime, backupLocation: "pg_backups/renku/renku-postgresql/2022-01-01T02:03:04.52Z/backup.sql.gz"},
+		{name: "backup-foo", schedule: "weekly", status: "complete", time: defaultTime, backupLocation: "pg_backups/renku/renku-postgresql/2022-01-01T02:03:04.52Z/backup.sql.gz", actionCount: 1, artifacts: defaultArtifacts},
+		{name: "backup-bar", schedule: "daily", status: "complete", time: defaultTime, backupLocation: "pg_backups/renku/renku-postgresql/2022-01-01T02:03:04.52Z/backup.sql.gz", actionCount: 1, artifacts: defaultArtifacts},
 	}
 	sort.Slice(expectedBackups, func(i, j int) bool { return expectedBackups[i].name < expectedBackups[j].name })
 	gvr := schema.GroupVersionResource{
@@ -76,14 +87,169 @@ func TestGetBackups(t *testing.T) {
 	backupConfig.KanisterNamespace = "kanister"
 	backupConfig.Name = "daily"
 
-	backups := getBackups(client, gvr, backupConfig)
+	backups, skipErrors, err := getBackups(client, gvr, backupConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(skipErrors) > 0 {
+		t.Fatal(skipErrors)
+	}
 	if len(backups) < 1 {
 		t.Fatal("Empty backups")
 	}
 	sort.Slice(backups, func(i, j int) bool { return expectedBackups[i].name < expectedBackups[j].name })
 	for i, backup := range backups {
-		if backup != expectedBackups[i] {
+		if !reflect.DeepEqual(backup, expectedBackups[i]) {
 			t.Fatal("Returned backup different from the expected one.")
 		}
 	}
 }
+
+func TestCategoriseBackups(t *testing.T) {
+	now := time.Now()
+	uncategorisedBackups := []backup{
+		{name: "backup-new", status: "complete", time: now.Add(-1 * time.Hour)},
+		{name: "backup-old", status: "complete", time: now.AddDate(0, 0, -10)},
+		{name: "backup-older", status: "complete", time: now.AddDate(0, 0, -20)},
+		{name: "backup-old-failed", status: "failed", time: now.AddDate(0, 0, -10)},
+		{name: "backup-pending", status: "pending", time: now},
+	}
+
+	var backupConfig backupconfig
+	backupConfig.Name = "daily"
+	backupConfig.Retention.Days = 7
+
+	categorisedBackups, expiredBackups, backupCounts := categoriseBackups(uncategorisedBackups, backupConfig)
+	if len(categorisedBackups) != 1 || categorisedBackups[0].name != "backup-new" {
+		t.Fatalf("Expected only backup-new to be in use, got %v", categorisedBackups)
+	}
+	if len(expiredBackups) != 2 || expiredBackups[0].name != "backup-older" || expiredBackups[1].name != "backup-old" {
+		t.Fatalf("Expected backup-older and backup-old to be expired, got %v", expiredBackups)
+	}
+	if backupCounts.failed != 1 || backupCounts.pending != 1 {
+		t.Fatalf("Unexpected backup counts: %+v", backupCounts)
+	}
+
+	// without age based retention no backup expires
+	backupConfig.Retention.Days = 0
+	categorisedBackups, expiredBackups, _ = categoriseBackups(uncategorisedBackups, backupConfig)
+	if len(categorisedBackups) != 3 || len(expiredBackups) != 0 {
+		t.Fatalf("Expected no expired backups without age retention, got %v in use and %v expired", len(categorisedBackups), len(expiredBackups))
+	}
+}
+
+func TestGetBackupConfigs(t *testing.T) {
+	clientSet := kubernetesfake.NewSimpleClientset(
+		&corev1.ConfigMap{
+			ObjectMeta: v1.ObjectMeta{Name: "taweret-backupconfig-daily", Namespace: configNamespace},
+			Data:       map[string]string{"backup-config.yaml": "name: daily\nkanisterNamespace: kanister\nretention:\n  backups: 7\n"},
+		},
+		&corev1.ConfigMap{
+			ObjectMeta: v1.ObjectMeta{Name: "taweret-backupconfig-broken", Namespace: configNamespace},
+			Data:       map[string]string{"backup-config.yaml": "name: [broken"},
+		},
+		&corev1.ConfigMap{
+			ObjectMeta: v1.ObjectMeta{Name: "unrelated", Namespace: configNamespace},
+			Data:       map[string]string{"foo": "bar"},
+		},
+	)
+
+	backupConfigs, configErrors := getBackupConfigs(clientSet, schema.GroupVersionResource{})
+	if len(backupConfigs) != 1 || backupConfigs[0].Name != "daily" || backupConfigs[0].Retention.Backups != 7 {
+		t.Fatalf("Expected only the daily backup config, got %+v", backupConfigs)
+	}
+	if len(configErrors) != 1 {
+		t.Fatalf("Expected one config error, got %v", configErrors)
+	}
+	var configErr *configerror
+	if !errors.As(configErrors[0], &configErr) || configErr.source != "configmap/kanister/taweret-backupconfig-broken" {
+		t.Fatalf("Expected the broken ConfigMap to be reported, got %v", configErrors[0])
+	}
+}
+
+func TestConfigDiscovery(t *testing.T) {
+	defer func(namespaces string, selector string) { *configNamespaces, *configSelector = namespaces, selector }(*configNamespaces, *configSelector)
+
+	backupConfigMap := func(namespace string, name string, labels map[string]string) *corev1.ConfigMap {
+		return &corev1.ConfigMap{
+			ObjectMeta: v1.ObjectMeta{Name: "taweret-backupconfig-" + name, Namespace: namespace, Labels: labels},
+			Data:       map[string]string{"backup-config.yaml": "name: " + name + "\nkanisterNamespace: " + namespace + "\n"},
+		}
+	}
+	selected := map[string]string{"taweret.io/backup-config": "true"}
+	clientSet := kubernetesfake.NewSimpleClientset(
+		backupConfigMap("kanister", "daily", selected),
+		backupConfigMap("team-a", "team-a-daily", selected),
+		backupConfigMap("team-a", "unlabelled", nil),
+		backupConfigMap("team-b", "team-b-daily", selected),
+	)
+	configNames := func() []string {
+		backupConfigs, configErrors := getBackupConfigs(clientSet, schema.GroupVersionResource{})
+		if len(configErrors) > 0 {
+			t.Fatal(configErrors)
+		}
+		var names []string
+		for _, backupConfig := range backupConfigs {
+			names = append(names, backupConfig.Name)
+		}
+		sort.Strings(names)
+		return names
+	}
+
+	*configNamespaces, *configSelector = "kanister, team-a", "taweret.io/backup-config=true"
+	if names := configNames(); !reflect.DeepEqual(names, []string{"daily", "team-a-daily"}) {
+		t.Fatalf("Expected the labelled configs of the watched namespaces, got %v", names)
+	}
+
+	*configNamespaces, *configSelector = allNamespaces, ""
+	if names := configNames(); !reflect.DeepEqual(names, []string{"daily", "team-a-daily", "team-b-daily", "unlabelled"}) {
+		t.Fatalf("Expected the configs of all namespaces, got %v", names)
+	}
+}
+
+func TestLoadKubeConfig(t *testing.T) {
+	kubeconfig := filepath.Join(t.TempDir(), "config")
+	err := os.WriteFile(kubeconfig, []byte(`apiVersion: v1
+kind: Config
+clusters:
+- name: staging
+  cluster:
+    server: https://staging.example.com
+- name: kind
+  cluster:
+    server: https://127.0.0.1:6443
+users:
+- name: developer
+  user:
+    token: secret
+contexts:
+- name: staging
+  context:
+    cluster: staging
+    user: developer
+- name: kind-taweret
+  context:
+    cluster: kind
+    user: developer
+current-context: staging
+`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := loadKubeConfig(kubeconfig, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Host != "https://staging.example.com" {
+		t.Fatalf("Expected the current context to be used, got %v", config.Host)
+	}
+
+	config, err = loadKubeConfig(kubeconfig, "kind-taweret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Host != "https://127.0.0.1:6443" {
+		t.Fatalf("Expected the selected context to be used, got %v", config.Host)
+	}
+}
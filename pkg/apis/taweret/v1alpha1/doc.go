@@ -0,0 +1,4 @@
+// Package v1alpha1 contains the v1alpha1 API of the taweret.io group, which is used to configure the retention of Kanister backups
+// +k8s:deepcopy-gen=package
+// +groupName=taweret.io
+package v1alpha1
@@ -0,0 +1,121 @@
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// +genclient
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// BackupPolicy defines the retention of the Kanister backups of one backup schedule. The name of the BackupPolicy is the value of the
+// backup-schedule option of the backup ActionSets it applies to.
+type BackupPolicy struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   BackupPolicySpec   `json:"spec"`
+	Status BackupPolicyStatus `json:"status,omitempty"`
+}
+
+// BackupPolicySpec is the desired retention of a BackupPolicy
+type BackupPolicySpec struct {
+	// KanisterNamespace is the namespace of the Kanister backup ActionSets
+	KanisterNamespace string `json:"kanisterNamespace"`
+	// BlueprintName is the Kanister Blueprint used to delete backups
+	BlueprintName string `json:"blueprintName"`
+	// ProfileName is the Kanister Profile used to delete backups
+	ProfileName string `json:"profileName"`
+	// BackupActionName is the blueprint action which creates backups, defaults to backup
+	BackupActionName string `json:"backupActionName,omitempty"`
+	// DeleteActionName is the blueprint action which deletes backups, defaults to delete
+	DeleteActionName string `json:"deleteActionName,omitempty"`
+	// ScheduleOptionKey is the ActionSet option which holds the backup schedule, defaults to backup-schedule
+	ScheduleOptionKey string `json:"scheduleOptionKey,omitempty"`
+	// ArtifactName is the backup artifact which holds the backup location, defaults to cloudObject
+	ArtifactName string `json:"artifactName,omitempty"`
+	// ArtifactKey is the key of the backup artifact which holds the backup location, defaults to backupLocation
+	ArtifactKey string `json:"artifactKey,omitempty"`
+	// ArtifactMapping renames backup artifacts to the input artifacts of the delete action
+	ArtifactMapping map[string]string `json:"artifactMapping,omitempty"`
+	// Retention defines which complete backups are kept
+	Retention RetentionSpec `json:"retention,omitempty"`
+	// MinKeep is the minimum number of complete backups which are never deleted, at least the min-keep flag of Taweret
+	MinKeep int32 `json:"minKeep,omitempty"`
+	// NewestBackupMaxAge withholds all deletions while the newest complete backup is older, defaults to the newest-backup-max-age flag of Taweret
+	NewestBackupMaxAge *metav1.Duration `json:"newestBackupMaxAge,omitempty"`
+	// DryRun only reports which backups would be deleted, without deleting them
+	DryRun bool `json:"dryRun,omitempty"`
+	// DeletionTimeout is how long to wait for a deletion ActionSet to complete, defaults to the deletion-timeout flag of Taweret
+	DeletionTimeout *metav1.Duration `json:"deletionTimeout,omitempty"`
+	// DeletionGracePeriod marks backups for deletion and only deletes them after the grace period, defaults to the deletion-grace-period flag of Taweret
+	DeletionGracePeriod *metav1.Duration `json:"deletionGracePeriod,omitempty"`
+	// DeletionMaxRetries is how often a failed deletion is retried, defaults to the deletion-max-retries flag of Taweret
+	DeletionMaxRetries *int32 `json:"deletionMaxRetries,omitempty"`
+	// DeletionRetryBackoff is how long to wait before retrying a failed deletion, doubled after every failed retry, defaults to the deletion-retry-backoff flag of Taweret
+	DeletionRetryBackoff *metav1.Duration `json:"deletionRetryBackoff,omitempty"`
+	// StalePendingAfter is how long a backup ActionSet can be pending before it is reported as stale, defaults to the stale-pending-after flag of Taweret
+	StalePendingAfter *metav1.Duration `json:"stalePendingAfter,omitempty"`
+	// ExpectedBackups defines how often complete backups are expected, to report missed backups
+	ExpectedBackups *ExpectedBackupsSpec `json:"expectedBackups,omitempty"`
+	// EvaluationSchedule is the cron expression on which the BackupPolicy is evaluated, defaults to the evaluation-schedule flag of Taweret
+	EvaluationSchedule string `json:"evaluationSchedule,omitempty"`
+	// Timezone is the timezone of the evaluation schedule, e.g. Europe/Zurich, defaults to the timezone flag of Taweret
+	Timezone string `json:"timezone,omitempty"`
+}
+
+// ExpectedBackupsSpec defines how often complete backups are expected, either every interval or on a schedule
+type ExpectedBackupsSpec struct {
+	// Interval is the longest time between two complete backups
+	Interval *metav1.Duration `json:"interval,omitempty"`
+	// Schedule is the cron expression on which backups are created, in the timezone of the BackupPolicy
+	Schedule string `json:"schedule,omitempty"`
+	// Tolerance is how late an expected backup can be before it is overdue
+	Tolerance *metav1.Duration `json:"tolerance,omitempty"`
+}
+
+// RetentionSpec defines which complete backups are kept
+type RetentionSpec struct {
+	// Backups is the number of newest backups to keep
+	Backups int32 `json:"backups,omitempty"`
+	// Minutes, Hours, Days, Months and Years together are the age after which backups expire
+	Minutes int32 `json:"minutes,omitempty"`
+	Hours   int32 `json:"hours,omitempty"`
+	Days    int32 `json:"days,omitempty"`
+	Months  int32 `json:"months,omitempty"`
+	Years   int32 `json:"years,omitempty"`
+	// KeepHourly, KeepDaily, KeepWeekly, KeepMonthly and KeepYearly are the grandfather-father-son retention buckets
+	KeepHourly  int32 `json:"keepHourly,omitempty"`
+	KeepDaily   int32 `json:"keepDaily,omitempty"`
+	KeepWeekly  int32 `json:"keepWeekly,omitempty"`
+	KeepMonthly int32 `json:"keepMonthly,omitempty"`
+	KeepYearly  int32 `json:"keepYearly,omitempty"`
+	// AllowZeroBackups allows Backups: 0 without retention buckets, which deletes every complete backup which has not expired
+	AllowZeroBackups bool `json:"allowZeroBackups,omitempty"`
+	// KeepFailed and KeepSkipped are the number of failed and skipped backup ActionSets kept for debugging, all are kept if unset
+	KeepFailed  *int32 `json:"keepFailed,omitempty"`
+	KeepSkipped *int32 `json:"keepSkipped,omitempty"`
+}
+
+// BackupPolicyStatus is the result of the last evaluation of a BackupPolicy by Taweret
+type BackupPolicyStatus struct {
+	// ObservedGeneration is the generation of the BackupPolicy which was last evaluated
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// LastEvaluationTime is the time the BackupPolicy was last evaluated
+	LastEvaluationTime *metav1.Time `json:"lastEvaluationTime,omitempty"`
+	// RetainedBackups is the number of complete backups retained by the last evaluation
+	RetainedBackups int32 `json:"retainedBackups"`
+	// DeletedBackups is the number of backups deleted by the last evaluation
+	DeletedBackups int32 `json:"deletedBackups"`
+	// Error is the error of the last evaluation, empty if the evaluation succeeded
+	Error string `json:"error,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// BackupPolicyList is a list of BackupPolicies
+type BackupPolicyList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+
+	Items []BackupPolicy `json:"items"`
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// reasons for which a backup is deleted
+const (
+	deletionReasonAge              string = "age"
+	deletionReasonCount            string = "count"
+	deletionReasonRetentionBuckets string = "retention-buckets"
+)
+
+type planneddeletion struct {
+	backup backup
+	reason string
+}
+
+// deletionplans keeps the latest deletion plan of every backup config and serves them over HTTP
+type deletionplans struct {
+	mutex sync.Mutex
+	plans map[string]deletionplan
+}
+
+// deletionplan is the JSON representation of the deletion plan of a backup config
+type deletionplan struct {
+	Config      string                `json:"config"`
+	DryRun      bool                  `json:"dryRun"`
+	EvaluatedAt time.Time             `json:"evaluatedAt"`
+	Deletions   []plannedDeletionJSON `json:"deletions"`
+}
+
+type plannedDeletionJSON struct {
+	ActionSet  string    `json:"actionSet"`
+	Action     int       `json:"action"`
+	BackupTime time.Time `json:"backupTime"`
+	Reason     string    `json:"reason"`
+}
+
+// determine which backups should be deleted and why: expired backups because of their age, and the backups in use which are not retained
+// by the retention buckets or exceed the count limit. Deletions which would violate a safeguard are withheld and counted per safeguard.
+func planDeletions(categorisedBackups []backup, expiredBackups []backup, backupConfig backupconfig) ([]planneddeletion, map[string]int) {
+	var plan []planneddeletion
+
+	for _, expiredBackup := range expiredBackups {
+		plan = append(plan, planneddeletion{backup: expiredBackup, reason: deletionReasonAge})
+	}
+	if len(expiredBackups) == 0 {
+		log.Printf("%v: no expired backups\n", backupConfig.Name)
+	}
+
+	// if grandfather-father-son retention is configured, delete every backup which is not retained by any bucket
+	// otherwise, if there are excess backups, delete the oldest excess
+	if gfsRetentionConfigured(backupConfig) {
+		unretainedBackups := selectUnretainedBackups(categorisedBackups, backupConfig)
+		for _, unretainedBackup := range unretainedBackups {
+			plan = append(plan, planneddeletion{backup: unretainedBackup, reason: deletionReasonRetentionBuckets})
+		}
+		if len(unretainedBackups) == 0 {
+			log.Printf("%v: all %v backups are retained by the retention buckets\n", backupConfig.Name, len(categorisedBackups))
+		}
+	} else if backupConfig.Retention.Backups == 0 && !backupConfig.Retention.AllowZeroBackups {
+		// never delete every backup by accident, validation rejects such configs as well
+		log.Printf("%v: retention.backups is 0 without allowZeroBackups, skipping count based deletions\n", backupConfig.Name)
+	} else if len(categorisedBackups) > int(backupConfig.Retention.Backups) {
+		excessBackups := sortBackups(categorisedBackups, backupConfig)[:len(categorisedBackups)-int(backupConfig.Retention.Backups)]
+		for _, excessBackup := range excessBackups {
+			plan = append(plan, planneddeletion{backup: excessBackup, reason: deletionReasonCount})
+		}
+	} else {
+		log.Printf("%v: backup count within limit: current: %v limit: %v\n", backupConfig.Name, len(categorisedBackups), backupConfig.Retention.Backups)
+	}
+
+	completeBackups := append(append([]backup{}, categorisedBackups...), expiredBackups...)
+	return applySafeguards(plan, completeBackups, backupConfig, time.Now())
+}
+
+func newDeletionPlans() *deletionplans {
+	return &deletionplans{plans: map[string]deletionplan{}}
+}
+
+// store the latest deletion plan of a backup config
+func (plans *deletionplans) set(plan []planneddeletion, backupConfig backupconfig) {
+	latestPlan := deletionplan{
+		Config:      backupConfig.Name,
+		DryRun:      backupConfig.DryRun,
+		EvaluatedAt: time.Now().UTC(),
+		Deletions:   []plannedDeletionJSON{},
+	}
+	for _, deletion := range plan {
+		latestPlan.Deletions = append(latestPlan.Deletions, plannedDeletionJSON{
+			ActionSet:  deletion.backup.name,
+			Action:     deletion.backup.action,
+			BackupTime: deletion.backup.time.UTC(),
+			Reason:     deletion.reason,
+		})
+	}
+
+	plans.mutex.Lock()
+	defer plans.mutex.Unlock()
+	plans.plans[backupConfig.Name] = latestPlan
+}
+
+// remove the deletion plan of a backup config which no longer exists
+func (plans *deletionplans) remove(backupConfigName string) {
+	plans.mutex.Lock()
+	defer plans.mutex.Unlock()
+	delete(plans.plans, backupConfigName)
+}
+
+// serve the latest deletion plans of all backup configs as JSON, or of a single backup config with the config query parameter
+func (plans *deletionplans) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	plans.mutex.Lock()
+	var response []deletionplan
+	for name, plan := range plans.plans {
+		if config := r.URL.Query().Get("config"); config != "" && config != name {
+			continue
+		}
+		response = append(response, plan)
+	}
+	plans.mutex.Unlock()
+
+	sort.Slice(response, func(q, p int) bool {
+		return response[q].Config < response[p].Config
+	})
+	if response == nil {
+		response = []deletionplan{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Printf("error encoding deletion plans: %v\n", err)
+	}
+}
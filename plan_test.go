@@ -0,0 +1,50 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestPlanDeletions(t *testing.T) {
+	now := time.Now()
+	categorisedBackups := []backup{
+		{name: "backup-1", status: "complete", time: now.Add(-3 * time.Hour)},
+		{name: "backup-2", status: "complete", time: now.Add(-2 * time.Hour)},
+		{name: "backup-3", status: "complete", time: now.Add(-1 * time.Hour)},
+	}
+	expiredBackups := []backup{
+		{name: "backup-0", status: "complete", time: now.AddDate(0, 0, -10)},
+	}
+
+	var backupConfig backupconfig
+	backupConfig.Name = "daily"
+	backupConfig.DryRun = true
+	backupConfig.Retention.Backups = 2
+	backupConfig.Retention.Days = 7
+
+	plan, _ := planDeletions(categorisedBackups, expiredBackups, backupConfig)
+	if len(plan) != 2 {
+		t.Fatalf("Expected two planned deletions, got %v", plan)
+	}
+	if plan[0].backup.name != "backup-0" || plan[0].reason != deletionReasonAge {
+		t.Fatalf("Expected backup-0 to be deleted because of its age, got %+v", plan[0])
+	}
+	if plan[1].backup.name != "backup-1" || plan[1].reason != deletionReasonCount {
+		t.Fatalf("Expected backup-1 to be deleted because of the count limit, got %+v", plan[1])
+	}
+
+	plans := newDeletionPlans()
+	plans.set(plan, backupConfig)
+
+	recorder := httptest.NewRecorder()
+	plans.ServeHTTP(recorder, httptest.NewRequest("GET", "/plan?config=daily", nil))
+	var response []deletionplan
+	if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	if len(response) != 1 || !response[0].DryRun || len(response[0].Deletions) != 2 || response[0].Deletions[1].ActionSet != "backup-1" {
+		t.Fatalf("Unexpected deletion plan: %+v", response)
+	}
+}
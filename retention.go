@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"time"
+)
+
+// a gfsbucket groups backups into periods, of which the newest backup of the most recent periods is retained
+type gfsbucket struct {
+	name  string
+	count StringInt
+	key   func(time.Time) string
+}
+
+// returns the grandfather-father-son retention buckets of a backup config
+func gfsBuckets(backupConfig backupconfig) []gfsbucket {
+	return []gfsbucket{
+		{name: "hourly", count: backupConfig.Retention.KeepHourly, key: func(t time.Time) string { return t.Format("2006-01-02 15") }},
+		{name: "daily", count: backupConfig.Retention.KeepDaily, key: func(t time.Time) string { return t.Format("2006-01-02") }},
+		{name: "weekly", count: backupConfig.Retention.KeepWeekly, key: func(t time.Time) string {
+			year, week := t.ISOWeek()
+			return fmt.Sprintf("%d-%02d", year, week)
+		}},
+		{name: "monthly", count: backupConfig.Retention.KeepMonthly, key: func(t time.Time) string { return t.Format("2006-01") }},
+		{name: "yearly", count: backupConfig.Retention.KeepYearly, key: func(t time.Time) string { return t.Format("2006") }},
+	}
+}
+
+// check whether any grandfather-father-son retention bucket is configured
+func gfsRetentionConfigured(backupConfig backupconfig) bool {
+	for _, bucket := range gfsBuckets(backupConfig) {
+		if bucket.count > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// select the backups which are not retained by the grandfather-father-son retention policy. A backup is retained if it is one of the newest
+// Retention.Backups backups, or if it is the newest backup in one of the most recent periods of any configured bucket.
+func selectUnretainedBackups(backups []backup, backupConfig backupconfig) []backup {
+	var unretainedBackups []backup
+
+	// work through the backups from newest to oldest
+	newestFirst := make([]backup, len(backups))
+	copy(newestFirst, backups)
+	sort.Slice(newestFirst, func(q, p int) bool {
+		return newestFirst[q].time.After(newestFirst[p].time)
+	})
+
+	buckets := gfsBuckets(backupConfig)
+	lastKeys := make([]string, len(buckets))
+	keptPerBucket := make([]int, len(buckets))
+
+	for i, aBackup := range newestFirst {
+		retained := i < int(backupConfig.Retention.Backups)
+		for b, bucket := range buckets {
+			if bucket.count <= 0 || keptPerBucket[b] >= int(bucket.count) {
+				continue
+			}
+			key := bucket.key(aBackup.time.UTC())
+			if key != lastKeys[b] {
+				lastKeys[b] = key
+				keptPerBucket[b]++
+				retained = true
+			}
+		}
+		if !retained {
+			unretainedBackups = append(unretainedBackups, aBackup)
+		}
+	}
+
+	log.Printf("%v: %v of %v backups are not retained by the retention buckets\n", backupConfig.Name, len(unretainedBackups), len(backups))
+
+	return sortBackups(unretainedBackups, backupConfig)
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSelectUnretainedBackups(t *testing.T) {
+	start, _ := time.Parse(time.RFC3339, "2022-01-31T12:00:00Z")
+
+	// two backups a day over forty days, the newest first
+	var backups []backup
+	for day := 0; day < 40; day++ {
+		for _, hour := range []int{6, 0} {
+			backups = append(backups, backup{
+				name:   start.AddDate(0, 0, -day).Add(time.Duration(hour) * time.Hour).Format(time.RFC3339),
+				status: "complete",
+				time:   start.AddDate(0, 0, -day).Add(time.Duration(hour) * time.Hour),
+			})
+		}
+	}
+
+	var backupConfig backupconfig
+	backupConfig.Name = "postgres"
+	backupConfig.Retention.KeepDaily = 3
+	backupConfig.Retention.KeepMonthly = 2
+
+	if !gfsRetentionConfigured(backupConfig) {
+		t.Fatal("Expected the retention buckets to be configured")
+	}
+
+	unretainedBackups := selectUnretainedBackups(backups, backupConfig)
+
+	// three daily backups, the newest one of January and the newest one of December are retained
+	retained := map[string]bool{}
+	for _, aBackup := range backups {
+		retained[aBackup.name] = true
+	}
+	for _, aBackup := range unretainedBackups {
+		delete(retained, aBackup.name)
+	}
+	expected := []string{"2022-01-31T18:00:00Z", "2022-01-30T18:00:00Z", "2022-01-29T18:00:00Z", "2021-12-31T18:00:00Z"}
+	if len(retained) != len(expected) {
+		t.Fatalf("Expected %v retained backups, got %v", len(expected), retained)
+	}
+	for _, name := range expected {
+		if !retained[name] {
+			t.Fatalf("Expected %v to be retained, got %v", name, retained)
+		}
+	}
+
+	// the oldest unretained backup comes first
+	if !unretainedBackups[0].time.Before(unretainedBackups[len(unretainedBackups)-1].time) {
+		t.Fatal("Expected unretained backups to be sorted chronologically")
+	}
+}
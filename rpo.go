@@ -0,0 +1,82 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// rpostatus is whether the newest complete backup of a backup config is older than its expected backups allow
+type rpostatus struct {
+	// whether the backup config expects backups at an interval or on a schedule
+	configured bool
+	// whether an expected backup is missing, and since when
+	violation bool
+	overdue   time.Duration
+}
+
+// the time by which the backup after the newest complete backup is expected, including the tolerance. The second return value is false
+// if the backup config does not expect backups.
+func (backupConfig backupconfig) expectedBackupDeadline(newest time.Time) (time.Time, bool, error) {
+	expected := backupConfig.ExpectedBackups
+	if expected.Interval == "" && expected.Schedule == "" {
+		return time.Time{}, false, nil
+	}
+	if expected.Interval != "" && expected.Schedule != "" {
+		return time.Time{}, true, errors.New("expectedBackups.interval and expectedBackups.schedule are mutually exclusive")
+	}
+
+	var tolerance time.Duration
+	if expected.Tolerance != "" {
+		var err error
+		if tolerance, err = time.ParseDuration(expected.Tolerance); err != nil || tolerance < 0 {
+			return time.Time{}, true, fmt.Errorf("expectedBackups.tolerance must be a duration which is not negative, e.g. 30m, got %v", expected.Tolerance)
+		}
+	}
+
+	if expected.Interval != "" {
+		interval, err := time.ParseDuration(expected.Interval)
+		if err != nil || interval <= 0 {
+			return time.Time{}, true, fmt.Errorf("expectedBackups.interval must be a positive duration, e.g. 24h, got %v", expected.Interval)
+		}
+		return newest.Add(interval + tolerance), true, nil
+	}
+
+	// the schedule is interpreted in the timezone of the backup config, like its evaluation schedule
+	_, timezone := backupConfig.evaluationSchedule()
+	schedule, err := cron.ParseStandard(fmt.Sprintf("CRON_TZ=%v %v", timezone, expected.Schedule))
+	if err != nil {
+		return time.Time{}, true, fmt.Errorf("expectedBackups.schedule %v is invalid: %w", expected.Schedule, err)
+	}
+	return schedule.Next(newest).Add(tolerance), true, nil
+}
+
+// computes whether the backup after the newest complete backup is overdue. Without any complete backup, the expected backup is overdue
+// indefinitely.
+func rpoStatus(newest time.Time, backupConfig backupconfig, now time.Time) rpostatus {
+	if newest.IsZero() {
+		if _, configured, err := backupConfig.expectedBackupDeadline(now); !configured || err != nil {
+			return rpostatus{}
+		}
+		return rpostatus{configured: true, violation: true, overdue: time.Duration(math.MaxInt64)}
+	}
+	deadline, configured, err := backupConfig.expectedBackupDeadline(newest)
+	if !configured || err != nil {
+		return rpostatus{}
+	}
+	if !now.After(deadline) {
+		return rpostatus{configured: true}
+	}
+	return rpostatus{configured: true, violation: true, overdue: now.Sub(deadline)}
+}
+
+// the overdue seconds of an RPO status as metric value, +Inf if there is no complete backup
+func (status rpostatus) overdueSeconds() float64 {
+	if status.overdue == time.Duration(math.MaxInt64) {
+		return math.Inf(1)
+	}
+	return status.overdue.Seconds()
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestRPOStatus(t *testing.T) {
+	now := time.Date(2022, 1, 10, 3, 0, 0, 0, time.UTC)
+
+	var intervalConfig backupconfig
+	intervalConfig.Name = "daily"
+	intervalConfig.ExpectedBackups.Interval = "24h"
+
+	var scheduleConfig backupconfig
+	scheduleConfig.Name = "nightly"
+	scheduleConfig.Timezone = "UTC"
+	scheduleConfig.ExpectedBackups.Schedule = "0 2 * * *"
+
+	tests := []struct {
+		name      string
+		config    backupconfig
+		newest    time.Time
+		tolerance string
+		expected  rpostatus
+	}{
+		{"unconfigured", backupconfig{}, now.Add(-48 * time.Hour), "", rpostatus{}},
+		{"interval kept", intervalConfig, now.Add(-23 * time.Hour), "", rpostatus{configured: true}},
+		{"interval overdue", intervalConfig, now.Add(-25 * time.Hour), "", rpostatus{configured: true, violation: true, overdue: time.Hour}},
+		{"interval within tolerance", intervalConfig, now.Add(-25 * time.Hour), "2h", rpostatus{configured: true}},
+		{"schedule kept", scheduleConfig, time.Date(2022, 1, 10, 2, 0, 5, 0, time.UTC), "", rpostatus{configured: true}},
+		{"schedule missed", scheduleConfig, time.Date(2022, 1, 9, 2, 0, 5, 0, time.UTC), "", rpostatus{configured: true, violation: true, overdue: time.Hour}},
+		{"schedule within tolerance", scheduleConfig, time.Date(2022, 1, 9, 2, 0, 5, 0, time.UTC), "90m", rpostatus{configured: true}},
+	}
+	for _, test := range tests {
+		test.config.ExpectedBackups.Tolerance = test.tolerance
+		if status := rpoStatus(test.newest, test.config, now); status != test.expected {
+			t.Fatalf("%v: expected %+v, got %+v", test.name, test.expected, status)
+		}
+	}
+
+	// without any complete backup, the expected backup is overdue indefinitely
+	status := rpoStatus(time.Time{}, intervalConfig, now)
+	if !status.violation || !math.IsInf(status.overdueSeconds(), 1) {
+		t.Fatalf("Expected a violation without complete backups, got %+v", status)
+	}
+}
+
+func TestRPOMetrics(t *testing.T) {
+	now := time.Now()
+	backups := []backup{
+		{name: "backup-old", status: "complete", time: now.Add(-30 * time.Hour)},
+		{name: "backup-held", status: "complete", time: now.Add(-26 * time.Hour), held: true},
+		{name: "backup-failed", status: "failed", time: now.Add(-1 * time.Hour)},
+	}
+
+	backupConfig := newValidBackupConfig("daily", "")
+	backupConfig.ExpectedBackups.Interval = "24h"
+
+	categorisedBackups, _, backupCounts := categoriseBackups(backups, backupConfig)
+	if !backupCounts.rpo.violation || backupCounts.rpo.overdue < 2*time.Hour || backupCounts.rpo.overdue > 2*time.Hour+time.Minute {
+		t.Fatalf("Expected the held backup to be the newest complete backup, 2h overdue, got %+v", backupCounts.rpo)
+	}
+
+	taweretMetrics := newTaweretMetrics()
+	taweretMetrics.setMetrics(categorisedBackups, backupConfig, backupCounts)
+	if violation := testutil.ToFloat64(taweretMetrics.rpoViolation.WithLabelValues("daily")); violation != 1 {
+		t.Fatalf("Expected an RPO violation, got %v", violation)
+	}
+
+	// the metrics are removed when the backup config no longer expects backups
+	backupConfig.ExpectedBackups.Interval = ""
+	categorisedBackups, _, backupCounts = categoriseBackups(backups, backupConfig)
+	taweretMetrics.setMetrics(categorisedBackups, backupConfig, backupCounts)
+	if count := testutil.CollectAndCount(taweretMetrics.overdueSeconds); count != 0 {
+		t.Fatalf("Expected no overdue metrics, got %v", count)
+	}
+}
+
+func TestValidateExpectedBackups(t *testing.T) {
+	backupConfig := newValidBackupConfig("daily", "")
+	backupConfig.ExpectedBackups.Interval = "24h"
+	backupConfig.ExpectedBackups.Schedule = "0 2 * * *"
+	if err := validateBackupConfig(backupConfig); err == nil || !strings.Contains(err.Error(), "mutually exclusive") {
+		t.Fatalf("Expected interval and schedule to be rejected together, got %v", err)
+	}
+
+	backupConfig.ExpectedBackups.Interval = ""
+	backupConfig.ExpectedBackups.Schedule = "at night"
+	if err := validateBackupConfig(backupConfig); err == nil || !strings.Contains(err.Error(), "expectedBackups.schedule") {
+		t.Fatalf("Expected an invalid schedule to be rejected, got %v", err)
+	}
+}
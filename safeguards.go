@@ -0,0 +1,64 @@
+package main
+
+import (
+	"log"
+	"sort"
+	"time"
+)
+
+// safeguards which withhold planned deletions
+const (
+	// fewer than minKeep complete backups would remain
+	guardMinKeep = "min-keep"
+	// the newest complete backup is older than the newest backup max age, i.e. backups are failing
+	guardStaleBackups = "stale-backups"
+)
+
+// the minimum number of complete backups of the backup config, the larger of the config and the min-keep flag
+func (backupConfig backupconfig) minKeep() int {
+	return maxInt(int(backupConfig.MinKeep), *globalMinKeep)
+}
+
+// the age of the newest complete backup after which no backups are deleted, zero if the guard is disabled
+func (backupConfig backupconfig) newestBackupMaxAge() time.Duration {
+	if maxAge, err := time.ParseDuration(backupConfig.NewestBackupMaxAge); err == nil && maxAge > 0 {
+		return maxAge
+	}
+	return *globalNewestBackupMaxAge
+}
+
+// withholds planned deletions which would violate a safeguard, whatever the reason of the deletion.
+// Returns the remaining plan and the number of withheld deletions per safeguard.
+func applySafeguards(plan []planneddeletion, completeBackups []backup, backupConfig backupconfig, now time.Time) ([]planneddeletion, map[string]int) {
+	withheld := map[string]int{guardMinKeep: 0, guardStaleBackups: 0}
+	if len(plan) == 0 {
+		return plan, withheld
+	}
+
+	// if the newest complete backup is too old, the old backups may be the only good ones left
+	if maxAge := backupConfig.newestBackupMaxAge(); maxAge > 0 {
+		var newest time.Time
+		for _, completeBackup := range completeBackups {
+			if completeBackup.time.After(newest) {
+				newest = completeBackup.time
+			}
+		}
+		if now.Sub(newest) > maxAge {
+			log.Printf("%v: newest complete backup from %v is older than %v, withholding all %v planned deletions\n", backupConfig.Name, newest.UTC(), maxAge, len(plan))
+			withheld[guardStaleBackups] = len(plan)
+			return nil, withheld
+		}
+	}
+
+	// delete the oldest planned backups first, and only as many as leave minKeep complete backups
+	maxDeletions := maxInt(len(completeBackups)-backupConfig.minKeep(), 0)
+	if len(plan) > maxDeletions {
+		sorted := make([]planneddeletion, len(plan))
+		copy(sorted, plan)
+		sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].backup.time.Before(sorted[j].backup.time) })
+		log.Printf("%v: keeping at least %v of %v complete backups, withholding %v of %v planned deletions\n", backupConfig.Name, backupConfig.minKeep(), len(completeBackups), len(plan)-maxDeletions, len(plan))
+		withheld[guardMinKeep] = len(plan) - maxDeletions
+		plan = sorted[:maxDeletions]
+	}
+	return plan, withheld
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMinKeepSafeguard(t *testing.T) {
+	now := time.Now()
+	expiredBackups := []backup{
+		{name: "backup-0", status: "complete", time: now.AddDate(0, 0, -12)},
+		{name: "backup-1", status: "complete", time: now.AddDate(0, 0, -11)},
+		{name: "backup-2", status: "complete", time: now.AddDate(0, 0, -10)},
+	}
+
+	var backupConfig backupconfig
+	backupConfig.Name = "daily"
+	backupConfig.Retention.Backups = 5
+	backupConfig.Retention.Days = 7
+
+	// every backup expired, e.g. because backups stopped or the clock is skewed, the global min-keep keeps the newest one
+	plan, withheld := planDeletions(nil, expiredBackups, backupConfig)
+	if len(plan) != 2 || plan[0].backup.name != "backup-0" || plan[1].backup.name != "backup-1" {
+		t.Fatalf("Expected the two oldest backups to be deleted, got %+v", plan)
+	}
+	if withheld[guardMinKeep] != 1 {
+		t.Fatalf("Expected one withheld deletion, got %v", withheld)
+	}
+
+	backupConfig.MinKeep = 2
+	plan, withheld = planDeletions(nil, expiredBackups, backupConfig)
+	if len(plan) != 1 || plan[0].backup.name != "backup-0" || withheld[guardMinKeep] != 2 {
+		t.Fatalf("Expected the config min keep to keep two backups, got %+v, withheld %v", plan, withheld)
+	}
+
+	// count based deletions are limited as well
+	backupConfig.Retention.Days = 0
+	backupConfig.Retention.Backups = 0
+	backupConfig.Retention.AllowZeroBackups = true
+	plan, _ = planDeletions(expiredBackups, nil, backupConfig)
+	if len(plan) != 1 {
+		t.Fatalf("Expected min keep to limit count based deletions, got %+v", plan)
+	}
+}
+
+func TestStaleBackupsSafeguard(t *testing.T) {
+	now := time.Now()
+	backups := []backup{
+		{name: "backup-0", status: "complete", time: now.AddDate(0, 0, -12)},
+		{name: "backup-1", status: "complete", time: now.AddDate(0, 0, -11)},
+		{name: "backup-2", status: "complete", time: now.AddDate(0, 0, -3)},
+	}
+
+	var backupConfig backupconfig
+	backupConfig.Name = "daily"
+	backupConfig.Retention.Backups = 1
+	backupConfig.NewestBackupMaxAge = "48h"
+
+	plan, withheld := planDeletions(backups, nil, backupConfig)
+	if len(plan) != 0 || withheld[guardStaleBackups] != 2 {
+		t.Fatalf("Expected all deletions to be withheld while the newest backup is stale, got %+v, withheld %v", plan, withheld)
+	}
+
+	backupConfig.NewestBackupMaxAge = "96h"
+	plan, withheld = planDeletions(backups, nil, backupConfig)
+	if len(plan) != 2 || withheld[guardStaleBackups] != 0 {
+		t.Fatalf("Expected the deletions to be planned while backups are recent, got %+v, withheld %v", plan, withheld)
+	}
+}
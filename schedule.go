@@ -0,0 +1,143 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-co-op/gocron"
+)
+
+// evaluationscheduler runs one evaluation job per backup config on the evaluation schedule of the config
+type evaluationscheduler struct {
+	mutex sync.Mutex
+	jobs  map[string]*evaluationjob
+	// a stopped scheduler no longer schedules evaluations, e.g. after losing the leader lease
+	stopped bool
+	// evaluates a backup config, called by the jobs
+	evaluate func(backupconfig)
+	// called once the job of a removed backup config has stopped and its last evaluation has finished, may be nil
+	unscheduled func(string)
+}
+
+// evaluationjob is the scheduler of a single backup config, every config has its own scheduler because gocron uses one timezone per scheduler
+type evaluationjob struct {
+	name      string
+	schedule  string
+	timezone  string
+	scheduler *gocron.Scheduler
+	// the latest version of the backup config, read by the job without locking the evaluation scheduler
+	config atomic.Value
+	// set to 1 once the job is replaced or removed, so that it does not start evaluations while it is being stopped
+	removed int32
+}
+
+func newEvaluationScheduler(evaluate func(backupconfig)) *evaluationscheduler {
+	return &evaluationscheduler{jobs: map[string]*evaluationjob{}, evaluate: evaluate}
+}
+
+// the evaluation schedule and timezone of the backup config, defaulting to the evaluation-schedule and timezone flags
+func (backupConfig backupconfig) evaluationSchedule() (string, string) {
+	return valueOrDefault(backupConfig.EvaluationSchedule, *globalEvaluationSchedule), valueOrDefault(backupConfig.Timezone, *globalTimezone)
+}
+
+// registers a job for every new backup config, re-registers the jobs of configs whose schedule changed and removes the jobs of configs which no longer exist.
+// Configs whose schedule cannot be parsed are not scheduled and returned as config errors. Replaced and removed jobs are stopped in the
+// background, as stopping a job waits for its running evaluation.
+func (evaluationScheduler *evaluationscheduler) sync(backupConfigs []backupconfig) []error {
+	evaluationScheduler.mutex.Lock()
+	defer evaluationScheduler.mutex.Unlock()
+	if evaluationScheduler.stopped {
+		return nil
+	}
+
+	var configErrors []error
+	var stopping []*evaluationjob
+	configured := map[string]bool{}
+	for _, backupConfig := range backupConfigs {
+		configured[backupConfig.Name] = true
+		schedule, timezone := backupConfig.evaluationSchedule()
+
+		job, ok := evaluationScheduler.jobs[backupConfig.Name]
+		if ok && job.schedule == schedule && job.timezone == timezone {
+			// the job evaluates the latest version of the config
+			job.config.Store(backupConfig)
+			continue
+		}
+
+		newJob, err := evaluationScheduler.newJob(backupConfig, schedule, timezone)
+		if err != nil {
+			configErrors = append(configErrors, backupConfig.configError(err))
+			continue
+		}
+		if ok {
+			stopping = append(stopping, job)
+		}
+		evaluationScheduler.jobs[backupConfig.Name] = newJob
+	}
+
+	for name, job := range evaluationScheduler.jobs {
+		if !configured[name] {
+			log.Printf("%v: backup config removed, unscheduling evaluations\n", name)
+			stopping = append(stopping, job)
+			delete(evaluationScheduler.jobs, name)
+		}
+	}
+
+	for _, job := range stopping {
+		atomic.StoreInt32(&job.removed, 1)
+		go evaluationScheduler.stopJob(job)
+	}
+	return configErrors
+}
+
+// stops a replaced or removed job, waiting for its running evaluation. Once the job of a removed config has stopped, unscheduled is
+// called, unless a config with the same name has been scheduled again in the meantime.
+func (evaluationScheduler *evaluationscheduler) stopJob(job *evaluationjob) {
+	job.scheduler.Stop()
+
+	evaluationScheduler.mutex.Lock()
+	_, scheduled := evaluationScheduler.jobs[job.name]
+	evaluationScheduler.mutex.Unlock()
+	if !scheduled && evaluationScheduler.unscheduled != nil {
+		evaluationScheduler.unscheduled(job.name)
+	}
+}
+
+// creates and starts the scheduler of a backup config
+func (evaluationScheduler *evaluationscheduler) newJob(backupConfig backupconfig, schedule string, timezone string) (*evaluationjob, error) {
+	location, err := time.LoadLocation(timezone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid timezone %v: %w", timezone, err)
+	}
+
+	job := &evaluationjob{name: backupConfig.Name, schedule: schedule, timezone: timezone, scheduler: gocron.NewScheduler(location)}
+	job.config.Store(backupConfig)
+	scheduledJob, err := job.scheduler.Cron(schedule).Do(func() {
+		if atomic.LoadInt32(&job.removed) == 0 {
+			evaluationScheduler.evaluate(job.config.Load().(backupconfig))
+		}
+	})
+	if err != nil {
+		return nil, fmt.Errorf("invalid evaluation schedule %v: %w", schedule, err)
+	}
+	job.scheduler.StartAsync()
+	log.Printf("%v: first evaluation scheduled: %v, evaluation schedule: %v %v\n", job.name, scheduledJob.NextRun(), schedule, timezone)
+	return job, nil
+}
+
+// stops the schedulers of all backup configs and waits for their running evaluations, later syncs do not schedule evaluations anymore
+func (evaluationScheduler *evaluationscheduler) stop() {
+	evaluationScheduler.mutex.Lock()
+	evaluationScheduler.stopped = true
+	jobs := evaluationScheduler.jobs
+	evaluationScheduler.jobs = map[string]*evaluationjob{}
+	evaluationScheduler.mutex.Unlock()
+
+	for _, job := range jobs {
+		atomic.StoreInt32(&job.removed, 1)
+		job.scheduler.Stop()
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEvaluationSchedulerSync(t *testing.T) {
+	evaluationScheduler := newEvaluationScheduler(func(backupconfig) {})
+	defer evaluationScheduler.stop()
+
+	daily := backupconfig{Name: "daily"}
+	hourly := backupconfig{Name: "hourly", EvaluationSchedule: "0 * * * *", Timezone: "Europe/Zurich"}
+	if configErrors := evaluationScheduler.sync([]backupconfig{daily, hourly}); len(configErrors) != 0 {
+		t.Fatal(configErrors)
+	}
+	if len(evaluationScheduler.jobs) != 2 {
+		t.Fatalf("Expected one job per backup config, got %v", len(evaluationScheduler.jobs))
+	}
+	if job := evaluationScheduler.jobs["daily"]; job.schedule != *globalEvaluationSchedule || job.timezone != *globalTimezone {
+		t.Fatalf("Expected the default evaluation schedule, got %v %v", job.schedule, job.timezone)
+	}
+	if job := evaluationScheduler.jobs["hourly"]; job.schedule != "0 * * * *" || job.timezone != "Europe/Zurich" {
+		t.Fatalf("Expected the evaluation schedule of the config, got %v %v", job.schedule, job.timezone)
+	}
+
+	// an unchanged schedule keeps the job and updates its config, a changed schedule re-registers the job
+	dailyScheduler := evaluationScheduler.jobs["daily"].scheduler
+	hourlyScheduler := evaluationScheduler.jobs["hourly"].scheduler
+	daily.DryRun = true
+	hourly.EvaluationSchedule = "30 * * * *"
+	if configErrors := evaluationScheduler.sync([]backupconfig{daily, hourly}); len(configErrors) != 0 {
+		t.Fatal(configErrors)
+	}
+	if evaluationScheduler.jobs["daily"].scheduler != dailyScheduler || !evaluationScheduler.jobs["daily"].config.Load().(backupconfig).DryRun {
+		t.Fatal("Expected the daily job to be kept with the updated config")
+	}
+	if evaluationScheduler.jobs["hourly"].scheduler == hourlyScheduler || evaluationScheduler.jobs["hourly"].schedule != "30 * * * *" {
+		t.Fatal("Expected the hourly job to be re-registered with the new schedule")
+	}
+
+	// removed configs are unscheduled, invalid schedules are reported
+	broken := backupconfig{Name: "broken", Timezone: "Mars/Olympus_Mons"}
+	configErrors := evaluationScheduler.sync([]backupconfig{daily, broken})
+	if len(configErrors) != 1 {
+		t.Fatalf("Expected the invalid timezone to be reported, got %v", configErrors)
+	}
+	if _, ok := evaluationScheduler.jobs["hourly"]; ok {
+		t.Fatal("Expected the removed hourly config to be unscheduled")
+	}
+	if _, ok := evaluationScheduler.jobs["broken"]; ok || len(evaluationScheduler.jobs) != 1 {
+		t.Fatal("Expected only the daily config to be scheduled")
+	}
+
+	if configErrors := evaluationScheduler.sync([]backupconfig{{Name: "invalid", EvaluationSchedule: "every minute"}}); len(configErrors) != 1 {
+		t.Fatalf("Expected the invalid cron expression to be reported, got %v", configErrors)
+	}
+
+	// a stopped scheduler unschedules every config and ignores later changes
+	evaluationScheduler.stop()
+	if configErrors := evaluationScheduler.sync([]backupconfig{daily}); len(configErrors) != 0 || len(evaluationScheduler.jobs) != 0 {
+		t.Fatalf("Expected a stopped scheduler not to schedule evaluations, got %v jobs", len(evaluationScheduler.jobs))
+	}
+}
+
+func TestEvaluationSchedulerSyncDuringEvaluation(t *testing.T) {
+	started := make(chan string, 2)
+	release := make(chan struct{})
+	evaluationScheduler := newEvaluationScheduler(func(backupConfig backupconfig) {
+		started <- backupConfig.Name
+		<-release
+	})
+	unscheduled := make(chan string, 1)
+	evaluationScheduler.unscheduled = func(name string) { unscheduled <- name }
+	defer evaluationScheduler.stop()
+
+	daily := backupconfig{Name: "daily"}
+	hourly := backupconfig{Name: "hourly"}
+	if configErrors := evaluationScheduler.sync([]backupconfig{daily, hourly}); len(configErrors) != 0 {
+		t.Fatal(configErrors)
+	}
+	evaluationScheduler.jobs["daily"].scheduler.RunAll()
+	evaluationScheduler.jobs["hourly"].scheduler.RunAll()
+	for i := 0; i < 2; i++ {
+		select {
+		case <-started:
+		case <-time.After(5 * time.Second):
+			t.Fatal("Expected both evaluations to start")
+		}
+	}
+
+	// rescheduling and removing configs whose evaluations are running does not wait for the evaluations
+	synced := make(chan struct{})
+	go func() {
+		hourly.EvaluationSchedule = "0 * * * *"
+		evaluationScheduler.sync([]backupconfig{hourly})
+		close(synced)
+	}()
+	select {
+	case <-synced:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the sync not to wait for the running evaluations")
+	}
+	if _, ok := evaluationScheduler.jobs["daily"]; ok || evaluationScheduler.jobs["hourly"].schedule != "0 * * * *" {
+		t.Fatal("Expected daily to be unscheduled and hourly to be rescheduled")
+	}
+	select {
+	case name := <-unscheduled:
+		t.Fatalf("Expected %v to be unscheduled only after its evaluation finished", name)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// the removed config is reported once its evaluation finished, the rescheduled config is not
+	close(release)
+	select {
+	case name := <-unscheduled:
+		if name != "daily" {
+			t.Fatalf("Expected daily to be unscheduled, got %v", name)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected daily to be unscheduled after its evaluation finished")
+	}
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+)
+
+// with a deletion grace period, backups planned for deletion are first marked with this annotation, and only deleted once the grace period has passed
+const markedForDeletionAnnotation = "taweret.io/marked-for-deletion-at"
+
+// the annotation which marks a backup for deletion. Every backup action of an ActionSet with several backup actions, e.g. of different
+// backup configs, is marked separately, suffixed with the index of the action like the backup id.
+func (b backup) markedForDeletionKey() string {
+	if b.actionCount > 1 {
+		return fmt.Sprintf("%v-%v", markedForDeletionAnnotation, b.action)
+	}
+	return markedForDeletionAnnotation
+}
+
+// the grace period between marking a backup for deletion and deleting it, zero if backups are deleted immediately
+func (backupConfig backupconfig) deletionGracePeriod() time.Duration {
+	if gracePeriod, err := time.ParseDuration(backupConfig.DeletionGracePeriod); err == nil && gracePeriod > 0 {
+		return gracePeriod
+	}
+	return *globalDeletionGracePeriod
+}
+
+// softdeletion is the result of applying the deletion grace period to a deletion plan
+type softdeletion struct {
+	// planned deletions whose grace period has passed
+	due []planneddeletion
+	// backups to mark and to unmark, a marked backup which is no longer planned for deletion is unmarked, e.g. after a policy change
+	mark   []backup
+	unmark []backup
+	// planned deletions within their grace period
+	pending int
+}
+
+// splits the planned deletions into the deletions which are due and the deletions within their grace period
+func applyGracePeriod(plan []planneddeletion, backups []backup, backupConfig backupconfig, now time.Time) softdeletion {
+	var result softdeletion
+	gracePeriod := backupConfig.deletionGracePeriod()
+
+	planned := map[string]bool{}
+	marked := map[string]bool{}
+	for _, deletion := range plan {
+		planned[deletion.backup.id()] = true
+		switch {
+		case gracePeriod <= 0:
+			result.due = append(result.due, deletion)
+		case deletion.backup.markedForDeletionAt.IsZero():
+			if !marked[deletion.backup.id()] {
+				marked[deletion.backup.id()] = true
+				result.mark = append(result.mark, deletion.backup)
+			}
+			result.pending++
+		case now.Sub(deletion.backup.markedForDeletionAt) >= gracePeriod:
+			result.due = append(result.due, deletion)
+		default:
+			result.pending++
+		}
+	}
+
+	unmarked := map[string]bool{}
+	for _, aBackup := range backups {
+		if !aBackup.markedForDeletionAt.IsZero() && aBackup.status == "complete" && !planned[aBackup.id()] && !unmarked[aBackup.id()] {
+			unmarked[aBackup.id()] = true
+			result.unmark = append(result.unmark, aBackup)
+		}
+	}
+	return result
+}
+
+// marks and unmarks the backups of a soft deletion on their ActionSets
+func applySoftDeletion(softDeletion softdeletion, dynamicClient dynamic.Interface, gvr schema.GroupVersionResource, backupConfig backupconfig, now time.Time) error {
+	markedAt := now.UTC().Format(time.RFC3339)
+	for _, aBackup := range softDeletion.mark {
+		log.Printf("%v: marking backup %v for deletion after %v\n", backupConfig.Name, aBackup.id(), now.Add(backupConfig.deletionGracePeriod()).UTC())
+		if err := patchActionSetAnnotations(dynamicClient, gvr, backupConfig.KanisterNamespace, aBackup.name, map[string]interface{}{aBackup.markedForDeletionKey(): markedAt}); err != nil {
+			return fmt.Errorf("error marking backup %v for deletion: %w", aBackup.id(), err)
+		}
+	}
+	for _, aBackup := range softDeletion.unmark {
+		log.Printf("%v: backup %v is no longer planned for deletion, unmarking\n", backupConfig.Name, aBackup.id())
+		if err := patchActionSetAnnotations(dynamicClient, gvr, backupConfig.KanisterNamespace, aBackup.name, map[string]interface{}{aBackup.markedForDeletionKey(): nil}); err != nil {
+			return fmt.Errorf("error unmarking backup %v: %w", aBackup.id(), err)
+		}
+	}
+	return nil
+}
+
+// restores a backup marked for deletion by removing the mark. The backup is held for one grace period, so that the next evaluation does
+// not mark it again right away, an existing longer hold is kept. Returns whether the backup is held and when the hold expires, zero if it
+// is held indefinitely.
+func restoreBackup(dynamicClient dynamic.Interface, gvr schema.GroupVersionResource, backupConfig backupconfig, name string, now time.Time) (bool, time.Time, error) {
+	actionset, err := dynamicClient.Resource(gvr).Namespace(backupConfig.KanisterNamespace).Get(context.Background(), name, v1.GetOptions{})
+	if err != nil {
+		return false, time.Time{}, fmt.Errorf("error retrieving backup actionset %v: %w", name, err)
+	}
+	// only the marks of the backup actions of the backup config are removed
+	backups, _ := parseBackupActionSet(*actionset, backupConfig)
+	if len(backups) == 0 {
+		return false, time.Time{}, fmt.Errorf("backup actionset %v has no backup action with %v %v", name, backupConfig.scheduleOptionKey(), backupConfig.Name)
+	}
+	annotations := map[string]interface{}{}
+	for _, aBackup := range backups {
+		if _, ok := actionset.GetAnnotations()[aBackup.markedForDeletionKey()]; ok {
+			annotations[aBackup.markedForDeletionKey()] = nil
+		}
+	}
+	if len(annotations) == 0 {
+		return false, time.Time{}, fmt.Errorf("backup actionset %v is not marked for deletion", name)
+	}
+
+	held, holdUntil, _ := parseHold(actionset.GetLabels(), actionset.GetAnnotations())
+	held = held && (holdUntil.IsZero() || now.Before(holdUntil))
+	gracePeriod := backupConfig.deletionGracePeriod()
+	switch {
+	case held && holdUntil.IsZero():
+		log.Printf("%v: restoring backup actionset %v, which stays held indefinitely\n", backupConfig.Name, name)
+	case gracePeriod > 0 && (!held || holdUntil.Before(now.Add(gracePeriod))):
+		holdUntil = now.Add(gracePeriod).UTC()
+		log.Printf("%v: restoring backup actionset %v, holding it until %v\n", backupConfig.Name, name, holdUntil)
+		held = true
+		annotations[holdKey] = "true"
+		annotations[holdUntilAnnotation] = holdUntil.Format(time.RFC3339)
+	default:
+		log.Printf("%v: restoring backup actionset %v\n", backupConfig.Name, name)
+	}
+	if err := patchActionSetAnnotations(dynamicClient, gvr, backupConfig.KanisterNamespace, name, annotations); err != nil {
+		return false, time.Time{}, fmt.Errorf("error restoring backup actionset %v: %w", name, err)
+	}
+	return held, holdUntil, nil
+}
+
+// sets annotations of an ActionSet with a merge patch, nil values remove the annotation
+func patchActionSetAnnotations(dynamicClient dynamic.Interface, gvr schema.GroupVersionResource, namespace string, name string, annotations map[string]interface{}) error {
+	patch, err := json.Marshal(map[string]interface{}{"metadata": map[string]interface{}{"annotations": annotations}})
+	if err != nil {
+		return err
+	}
+	_, err = dynamicClient.Resource(gvr).Namespace(namespace).Patch(context.Background(), name, types.MergePatchType, patch, v1.PatchOptions{})
+	return err
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	fake "k8s.io/client-go/dynamic/fake"
+)
+
+func TestApplyGracePeriod(t *testing.T) {
+	now := time.Now()
+	backups := []backup{
+		{name: "backup-new", status: "complete", time: now.AddDate(0, 0, -8)},
+		{name: "backup-marked", status: "complete", time: now.AddDate(0, 0, -9), markedForDeletionAt: now.Add(-1 * time.Hour)},
+		{name: "backup-due", status: "complete", time: now.AddDate(0, 0, -10), markedForDeletionAt: now.Add(-73 * time.Hour)},
+		{name: "backup-kept", status: "complete", time: now.Add(-1 * time.Hour), markedForDeletionAt: now.Add(-2 * time.Hour)},
+	}
+	plan := []planneddeletion{
+		{backup: backups[0], reason: deletionReasonAge},
+		{backup: backups[1], reason: deletionReasonAge},
+		{backup: backups[2], reason: deletionReasonAge},
+	}
+
+	var backupConfig backupconfig
+	backupConfig.Name = "daily"
+	backupConfig.DeletionGracePeriod = "72h"
+
+	softDeletion := applyGracePeriod(plan, backups, backupConfig, now)
+	if len(softDeletion.due) != 1 || softDeletion.due[0].backup.name != "backup-due" {
+		t.Fatalf("Expected only the backup marked more than 72h ago to be due, got %+v", softDeletion.due)
+	}
+	if len(softDeletion.mark) != 1 || softDeletion.mark[0].name != "backup-new" || softDeletion.pending != 2 {
+		t.Fatalf("Expected backup-new to be marked and two deletions to be pending, got %+v", softDeletion)
+	}
+	if len(softDeletion.unmark) != 1 || softDeletion.unmark[0].name != "backup-kept" {
+		t.Fatalf("Expected the marked backup which is no longer planned to be unmarked, got %v", softDeletion.unmark)
+	}
+
+	backupConfig.DeletionGracePeriod = ""
+	softDeletion = applyGracePeriod(plan, backups, backupConfig, now)
+	if len(softDeletion.due) != 3 || len(softDeletion.mark) != 0 || softDeletion.pending != 0 {
+		t.Fatalf("Expected every planned deletion to be due without a grace period, got %+v", softDeletion)
+	}
+}
+
+func TestSoftDeleteMultipleBackupConfigs(t *testing.T) {
+	gvr := schema.GroupVersionResource{Group: "cr.kanister.io", Version: "v1alpha1", Resource: "actionsets"}
+	now := time.Now().UTC()
+
+	// one actionset with a backup action of the daily and of the weekly backup config
+	actionset := newUnstructuredBackup("backup-both", "kanister", now.AddDate(0, 0, -10).Format(time.RFC3339), "backup", "daily", "complete", "pg_backups/daily/backup.sql.gz")
+	weeklyAction := newUnstructuredBackup("", "", "", "backup", "weekly", "complete", "pg_backups/weekly/backup.sql.gz")
+	for _, field := range [][]string{{"spec", "actions"}, {"status", "actions"}} {
+		actions, _, _ := unstructured.NestedSlice(actionset.Object, field...)
+		weekly, _, _ := unstructured.NestedSlice(weeklyAction.Object, field...)
+		if err := unstructured.SetNestedSlice(actionset.Object, append(actions, weekly...), field...); err != nil {
+			t.Fatal(err)
+		}
+	}
+	dynamicClient := fake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{gvr: "ActionSetsList"}, actionset)
+
+	var daily, weekly backupconfig
+	daily.Name, weekly.Name = "daily", "weekly"
+	daily.KanisterNamespace, weekly.KanisterNamespace = "kanister", "kanister"
+	daily.DeletionGracePeriod, weekly.DeletionGracePeriod = "72h", "72h"
+
+	// the daily config marks its backup
+	dailyBackups, _, err := getBackups(dynamicClient, gvr, daily)
+	if err != nil {
+		t.Fatal(err)
+	}
+	softDeletion := applyGracePeriod([]planneddeletion{{backup: dailyBackups[0], reason: deletionReasonAge}}, dailyBackups, daily, now)
+	if err := applySoftDeletion(softDeletion, dynamicClient, gvr, daily, now); err != nil {
+		t.Fatal(err)
+	}
+
+	// the weekly config does not plan its backup, which neither unmarks the daily backup nor is marked itself
+	weeklyBackups, _, err := getBackups(dynamicClient, gvr, weekly)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(weeklyBackups) != 1 || !weeklyBackups[0].markedForDeletionAt.IsZero() {
+		t.Fatalf("Expected the weekly backup not to be marked, got %+v", weeklyBackups)
+	}
+	softDeletion = applyGracePeriod(nil, weeklyBackups, weekly, now)
+	if len(softDeletion.unmark) != 0 {
+		t.Fatalf("Expected the weekly config not to unmark the daily backup, got %+v", softDeletion.unmark)
+	}
+
+	dailyBackups, _, err = getBackups(dynamicClient, gvr, daily)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dailyBackups) != 1 || !dailyBackups[0].markedForDeletionAt.Equal(now.Truncate(time.Second)) {
+		t.Fatalf("Expected the daily backup to keep its mark, got %+v", dailyBackups)
+	}
+}
+
+func TestRestoreBackup(t *testing.T) {
+	gvr := schema.GroupVersionResource{Group: "cr.kanister.io", Version: "v1alpha1", Resource: "actionsets"}
+	now := time.Now().UTC()
+	created := now.AddDate(0, 0, -10).Format(time.RFC3339)
+	markedAt := map[string]string{markedForDeletionAnnotation: now.Add(-1 * time.Hour).Format(time.RFC3339)}
+	marked := newUnstructuredBackup("backup-marked", "kanister", created, "backup", "daily", "complete", "pg_backups/marked/backup.sql.gz")
+	marked.SetAnnotations(markedAt)
+	heldForever := newUnstructuredBackup("backup-held", "kanister", created, "backup", "daily", "complete", "pg_backups/held/backup.sql.gz")
+	heldForever.SetAnnotations(map[string]string{markedForDeletionAnnotation: markedAt[markedForDeletionAnnotation], holdKey: "true"})
+	weekly := newUnstructuredBackup("backup-weekly", "kanister", created, "backup", "weekly", "complete", "pg_backups/weekly/backup.sql.gz")
+	weekly.SetAnnotations(markedAt)
+	unmarked := newUnstructuredBackup("backup-unmarked", "kanister", created, "backup", "daily", "complete", "pg_backups/unmarked/backup.sql.gz")
+	dynamicClient := fake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{gvr: "ActionSetsList"},
+		marked, heldForever, weekly, unmarked)
+
+	var backupConfig backupconfig
+	backupConfig.Name = "daily"
+	backupConfig.KanisterNamespace = "kanister"
+	backupConfig.DeletionGracePeriod = "72h"
+
+	// only marked backups of the backup config are restored
+	for _, name := range []string{"backup-weekly", "backup-unmarked", "backup-missing"} {
+		if _, _, err := restoreBackup(dynamicClient, gvr, backupConfig, name, now); err == nil {
+			t.Fatalf("Expected restoring %v to fail", name)
+		}
+	}
+
+	held, holdUntil, err := restoreBackup(dynamicClient, gvr, backupConfig, "backup-marked", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !held || !holdUntil.Equal(now.Add(72*time.Hour)) {
+		t.Fatalf("Expected the restored backup to be held for one grace period, got %v, %v", held, holdUntil)
+	}
+	restored, err := dynamicClient.Resource(gvr).Namespace("kanister").Get(context.Background(), "backup-marked", v1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	annotations := restored.GetAnnotations()
+	if _, ok := annotations[markedForDeletionAnnotation]; ok || annotations[holdKey] != "true" || annotations[holdUntilAnnotation] != now.Add(72*time.Hour).Format(time.RFC3339) {
+		t.Fatalf("Expected the restored backup to be unmarked and held for the grace period, got %v", annotations)
+	}
+
+	// an indefinite hold is not shortened
+	held, holdUntil, err = restoreBackup(dynamicClient, gvr, backupConfig, "backup-held", now)
+	if err != nil || !held || !holdUntil.IsZero() {
+		t.Fatalf("Expected the backup to stay held indefinitely, got %v, %v, %v", held, holdUntil, err)
+	}
+	restored, err = dynamicClient.Resource(gvr).Namespace("kanister").Get(context.Background(), "backup-held", v1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := restored.GetAnnotations()[holdUntilAnnotation]; ok {
+		t.Fatalf("Expected the indefinite hold to be kept, got %v", restored.GetAnnotations())
+	}
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// checks a backup config for missing fields and invalid values, returning every problem in a single error
+func validateBackupConfig(backupConfig backupconfig) error {
+	var problems []string
+
+	// required fields
+	required := map[string]string{
+		"name":              backupConfig.Name,
+		"kanisterNamespace": backupConfig.KanisterNamespace,
+		"blueprintName":     backupConfig.BlueprintName,
+		"profileName":       backupConfig.ProfileName,
+	}
+	for _, field := range []string{"name", "kanisterNamespace", "blueprintName", "profileName"} {
+		if strings.TrimSpace(required[field]) == "" {
+			problems = append(problems, field+" is required")
+		}
+	}
+
+	// retention values are counts and periods, which cannot be negative
+	retention := []struct {
+		field string
+		value StringInt
+	}{
+		{"backups", backupConfig.Retention.Backups},
+		{"minutes", backupConfig.Retention.Minutes},
+		{"hours", backupConfig.Retention.Hours},
+		{"days", backupConfig.Retention.Days},
+		{"months", backupConfig.Retention.Months},
+		{"years", backupConfig.Retention.Years},
+		{"keepHourly", backupConfig.Retention.KeepHourly},
+		{"keepDaily", backupConfig.Retention.KeepDaily},
+		{"keepWeekly", backupConfig.Retention.KeepWeekly},
+		{"keepMonthly", backupConfig.Retention.KeepMonthly},
+		{"keepYearly", backupConfig.Retention.KeepYearly},
+	}
+	for _, value := range retention {
+		if value.value < 0 {
+			problems = append(problems, fmt.Sprintf("retention.%v must not be negative, got %v", value.field, value.value))
+		}
+	}
+
+	// without retention buckets, backups: 0 deletes every complete backup
+	if backupConfig.Retention.Backups == 0 && !gfsRetentionConfigured(backupConfig) && !backupConfig.Retention.AllowZeroBackups {
+		problems = append(problems, "retention.backups is 0, which deletes every complete backup, set retention.backups or retention buckets, or retention.allowZeroBackups: true to delete every backup")
+	}
+
+	// age expiry would delete the backups kept by the retention buckets once they are older than the retention period
+	if _, ageRetention := retentionCutoff(backupConfig, time.Now()); ageRetention && gfsRetentionConfigured(backupConfig) {
+		problems = append(problems, "retention.minutes, hours, days, months and years cannot be combined with retention buckets, which would be deleted by age, use keepHourly, keepDaily, keepWeekly, keepMonthly or keepYearly instead")
+	}
+
+	if backupConfig.Retention.KeepFailed != nil && *backupConfig.Retention.KeepFailed < 0 {
+		problems = append(problems, fmt.Sprintf("retention.keepFailed must not be negative, got %v", *backupConfig.Retention.KeepFailed))
+	}
+	if backupConfig.Retention.KeepSkipped != nil && *backupConfig.Retention.KeepSkipped < 0 {
+		problems = append(problems, fmt.Sprintf("retention.keepSkipped must not be negative, got %v", *backupConfig.Retention.KeepSkipped))
+	}
+	if backupConfig.StalePendingAfter != "" {
+		if after, err := time.ParseDuration(backupConfig.StalePendingAfter); err != nil || after <= 0 {
+			problems = append(problems, fmt.Sprintf("stalePendingAfter must be a positive duration, e.g. 6h, got %v", backupConfig.StalePendingAfter))
+		}
+	}
+	if backupConfig.MinKeep < 0 {
+		problems = append(problems, fmt.Sprintf("minKeep must not be negative, got %v", backupConfig.MinKeep))
+	}
+	if backupConfig.NewestBackupMaxAge != "" {
+		if maxAge, err := time.ParseDuration(backupConfig.NewestBackupMaxAge); err != nil || maxAge <= 0 {
+			problems = append(problems, fmt.Sprintf("newestBackupMaxAge must be a positive duration, e.g. 48h, got %v", backupConfig.NewestBackupMaxAge))
+		}
+	}
+	if backupConfig.DeletionGracePeriod != "" {
+		if gracePeriod, err := time.ParseDuration(backupConfig.DeletionGracePeriod); err != nil || gracePeriod <= 0 {
+			problems = append(problems, fmt.Sprintf("deletionGracePeriod must be a positive duration, e.g. 72h, got %v", backupConfig.DeletionGracePeriod))
+		}
+	}
+	if backupConfig.DeletionMaxRetries != nil && *backupConfig.DeletionMaxRetries < 0 {
+		problems = append(problems, fmt.Sprintf("deletionMaxRetries must not be negative, got %v", *backupConfig.DeletionMaxRetries))
+	}
+	if backupConfig.DeletionRetryBackoff != "" {
+		if backoff, err := time.ParseDuration(backupConfig.DeletionRetryBackoff); err != nil || backoff <= 0 {
+			problems = append(problems, fmt.Sprintf("deletionRetryBackoff must be a positive duration, e.g. 10m, got %v", backupConfig.DeletionRetryBackoff))
+		}
+	}
+	if backupConfig.DeletionTimeout != "" {
+		if timeout, err := time.ParseDuration(backupConfig.DeletionTimeout); err != nil || timeout <= 0 {
+			problems = append(problems, fmt.Sprintf("deletionTimeout must be a positive duration, e.g. 45m, got %v", backupConfig.DeletionTimeout))
+		}
+	}
+	if _, _, err := backupConfig.expectedBackupDeadline(time.Now()); err != nil {
+		problems = append(problems, err.Error())
+	}
+	if backupConfig.Timezone != "" {
+		if _, err := time.LoadLocation(backupConfig.Timezone); err != nil {
+			problems = append(problems, fmt.Sprintf("timezone %v is unknown", backupConfig.Timezone))
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid backup config: %v", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// validates backup configs, rejecting invalid configs and configs whose name is already used by a config of another source.
+// Configs are checked in the order of their sources, so the same config wins every time.
+func validateBackupConfigs(backupConfigs []backupconfig) ([]backupconfig, []error) {
+	sorted := make([]backupconfig, len(backupConfigs))
+	copy(sorted, backupConfigs)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].source < sorted[j].source })
+
+	var validConfigs []backupconfig
+	var configErrors []error
+	sources := map[string]string{}
+	for _, backupConfig := range sorted {
+		if err := validateBackupConfig(backupConfig); err != nil {
+			configErrors = append(configErrors, backupConfig.configError(err))
+			continue
+		}
+		if source, ok := sources[backupConfig.Name]; ok {
+			configErrors = append(configErrors, backupConfig.configError(fmt.Errorf("duplicate backup config name %v, already defined in %v", backupConfig.Name, source)))
+			continue
+		}
+		sources[backupConfig.Name] = backupConfig.source
+		validConfigs = append(validConfigs, backupConfig)
+	}
+	return validConfigs, configErrors
+}
+
+// wraps an error about a backup config in a config error of its source
+func (backupConfig backupconfig) configError(err error) error {
+	return &configerror{source: valueOrDefault(backupConfig.source, backupConfig.Name), err: err, object: backupConfig.object}
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+func newValidBackupConfig(name string, source string) backupconfig {
+	var backupConfig backupconfig
+	backupConfig.Name = name
+	backupConfig.KanisterNamespace = "kanister"
+	backupConfig.BlueprintName = "postgres-bp"
+	backupConfig.ProfileName = "default-profile"
+	backupConfig.Retention.Backups = 7
+	backupConfig.source = source
+	return backupConfig
+}
+
+func TestStrictBackupConfigDecoding(t *testing.T) {
+	configMap := func(config string) *corev1.ConfigMap {
+		return &corev1.ConfigMap{
+			ObjectMeta: v1.ObjectMeta{Name: "taweret-backupconfig-daily", Namespace: configNamespace},
+			Data:       map[string]string{"backup-config.yaml": config},
+		}
+	}
+
+	_, _, err := backupConfigFromConfigMap(configMap("name: daily\nretenton:\n  backups: 7\n"))
+	if err == nil || !strings.Contains(err.Error(), "retenton") {
+		t.Fatalf("Expected the misspelled key to be reported, got %v", err)
+	}
+
+	backupConfig, ok, err := backupConfigFromConfigMap(configMap("name: daily\nretention:\n  backups: \"7\"\n  days: 3\n"))
+	if err != nil || !ok {
+		t.Fatal(err)
+	}
+	if backupConfig.Retention.Backups != 7 || backupConfig.Retention.Days != 3 {
+		t.Fatalf("Expected quoted and unquoted retention values, got %+v", backupConfig.Retention)
+	}
+	if backupConfig.source != "configmap/kanister/taweret-backupconfig-daily" {
+		t.Fatalf("Unexpected source %v", backupConfig.source)
+	}
+
+	// values which are not integers are errors instead of zero or truncated values
+	for _, backups := range []string{"true", "[7]", "{}", "7.5", "seven"} {
+		if _, _, err := backupConfigFromConfigMap(configMap("name: daily\nretention:\n  backups: " + backups + "\n")); err == nil {
+			t.Fatalf("Expected backups: %v to be rejected", backups)
+		}
+	}
+}
+
+func TestValidateBackupConfig(t *testing.T) {
+	if err := validateBackupConfig(newValidBackupConfig("daily", "")); err != nil {
+		t.Fatal(err)
+	}
+
+	missing := newValidBackupConfig("daily", "")
+	missing.BlueprintName = ""
+	missing.ProfileName = " "
+	if err := validateBackupConfig(missing); err == nil || !strings.Contains(err.Error(), "blueprintName is required") || !strings.Contains(err.Error(), "profileName is required") {
+		t.Fatalf("Expected the missing fields to be reported, got %v", err)
+	}
+
+	negative := newValidBackupConfig("daily", "")
+	negative.Retention.Days = -1
+	negative.DeletionTimeout = "soon"
+	negative.DeletionGracePeriod = "-72h"
+	negativeRetries := StringInt(-1)
+	negative.DeletionMaxRetries = &negativeRetries
+	negative.Retention.KeepFailed = &negativeRetries
+	if err := validateBackupConfig(negative); err == nil || !strings.Contains(err.Error(), "retention.keepFailed") || !strings.Contains(err.Error(), "retention.days") || !strings.Contains(err.Error(), "deletionTimeout") || !strings.Contains(err.Error(), "deletionGracePeriod") || !strings.Contains(err.Error(), "deletionMaxRetries") {
+		t.Fatalf("Expected the invalid values to be reported, got %v", err)
+	}
+
+	// backups: 0 is only allowed with retention buckets or an explicit opt-in
+	zero := newValidBackupConfig("daily", "")
+	zero.Retention.Backups = 0
+	zero.Retention.Days = 7
+	if err := validateBackupConfig(zero); err == nil || !strings.Contains(err.Error(), "allowZeroBackups") {
+		t.Fatalf("Expected backups: 0 to be rejected, got %v", err)
+	}
+	zero.Retention.AllowZeroBackups = true
+	if err := validateBackupConfig(zero); err != nil {
+		t.Fatal(err)
+	}
+	zero.Retention.AllowZeroBackups = false
+	zero.Retention.KeepDaily = 7
+	zero.Retention.Days = 0
+	if err := validateBackupConfig(zero); err != nil {
+		t.Fatal(err)
+	}
+
+	// age expiry would delete the backups kept by the retention buckets
+	combined := newValidBackupConfig("daily", "")
+	combined.Retention.Days = 7
+	combined.Retention.KeepMonthly = 12
+	if err := validateBackupConfig(combined); err == nil || !strings.Contains(err.Error(), "retention buckets") {
+		t.Fatalf("Expected age retention combined with retention buckets to be rejected, got %v", err)
+	}
+}
+
+func TestValidateBackupConfigs(t *testing.T) {
+	invalid := newValidBackupConfig("weekly", "configmap/kanister/weekly")
+	invalid.KanisterNamespace = ""
+	backupConfigs, configErrors := validateBackupConfigs([]backupconfig{
+		newValidBackupConfig("daily", "configmap/team-b/daily"),
+		newValidBackupConfig("daily", "configmap/team-a/daily"),
+		invalid,
+		newValidBackupConfig("monthly", "backuppolicy/kanister/monthly"),
+	})
+	if len(backupConfigs) != 2 || backupConfigs[0].Name != "monthly" || backupConfigs[1].source != "configmap/team-a/daily" {
+		t.Fatalf("Expected the first daily config and the monthly config, got %+v", backupConfigs)
+	}
+	if len(configErrors) != 2 {
+		t.Fatalf("Expected the duplicate and the invalid config to be rejected, got %v", configErrors)
+	}
+	for _, err := range configErrors {
+		var configErr *configerror
+		if !errors.As(err, &configErr) || (configErr.source != "configmap/team-b/daily" && configErr.source != "configmap/kanister/weekly") {
+			t.Fatalf("Unexpected config error %v", err)
+		}
+	}
+}
+
+func TestConfigErrorEvents(t *testing.T) {
+	recorder := record.NewFakeRecorder(1)
+	defer func(previous record.EventRecorder) { eventRecorder = previous }(eventRecorder)
+	eventRecorder = recorder
+
+	configMap := &corev1.ConfigMap{ObjectMeta: v1.ObjectMeta{Name: "taweret-backupconfig-daily", Namespace: configNamespace}}
+	taweretMetrics := newTaweretMetrics()
+	taweretMetrics.recordConfigError(&configerror{source: "configmap/kanister/taweret-backupconfig-daily", err: errors.New("invalid backup config: profileName is required"), object: configMap})
+
+	select {
+	case event := <-recorder.Events:
+		if !strings.Contains(event, eventReasonInvalidBackupConfig) || !strings.Contains(event, "profileName is required") {
+			t.Fatalf("Unexpected event %v", event)
+		}
+	default:
+		t.Fatal("Expected an event on the ConfigMap")
+	}
+}